@@ -0,0 +1,324 @@
+package ion
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+)
+
+// binaryVersionMarker begins every binary Ion 1.0 stream.
+var binaryVersionMarker = []byte{0xE0, 0x01, 0x00, 0xEA}
+
+// systemSymbols are the symbols of the Ion 1.0 system symbol table, which have SIDs 1 through 9
+// in every stream. Local symbols are numbered after them.
+var systemSymbols = []string{
+	"$ion", "$ion_1_0", "$ion_symbol_table", "name", "version", "imports", "symbols", "max_id",
+	"$ion_shared_symbol_table",
+}
+
+const (
+	sidSymbolTable = 3
+	sidSymbols     = 7
+)
+
+// binary type codes, the high nibble of a value's type descriptor byte.
+const (
+	binaryNull       = 0x00
+	binaryBool       = 0x10
+	binaryPosInt     = 0x20
+	binaryNegInt     = 0x30
+	binaryFloat      = 0x40
+	binaryDecimal    = 0x50
+	binaryTimestamp  = 0x60
+	binarySymbol     = 0x70
+	binaryString     = 0x80
+	binaryClob       = 0x90
+	binaryBlob       = 0xA0
+	binaryList       = 0xB0
+	binarySexp       = 0xC0
+	binaryStruct     = 0xD0
+	binaryAnnotation = 0xE0
+)
+
+var binaryTypeCodes = map[Type]byte{
+	NullType:      binaryNull,
+	BoolType:      binaryBool,
+	IntType:       binaryPosInt,
+	FloatType:     binaryFloat,
+	DecimalType:   binaryDecimal,
+	TimestampType: binaryTimestamp,
+	SymbolType:    binarySymbol,
+	StringType:    binaryString,
+	ClobType:      binaryClob,
+	BlobType:      binaryBlob,
+	ListType:      binaryList,
+	SexpType:      binarySexp,
+	StructType:    binaryStruct,
+}
+
+// MarshalBinary encodes the value as a complete binary Ion stream: the version marker, a local
+// symbol table declaring the field names, symbols, and annotations the value uses, and the value.
+func (v Value) MarshalBinary() ([]byte, error) {
+	e := binaryEncoder{sids: make(map[string]int)}
+	for i, name := range systemSymbols {
+		e.sids[name] = i + 1
+	}
+	body, err := e.encode(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Write(binaryVersionMarker)
+	if len(e.symbols) > 0 {
+		buf.Write(e.symbolTable())
+	}
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+// binaryEncoder assigns symbol IDs as it encodes, so the symbol table is known once it is done.
+type binaryEncoder struct {
+	sids    map[string]int
+	symbols []string //local symbols, in SID order
+}
+
+func (e *binaryEncoder) sid(name string) int {
+	if sid, ok := e.sids[name]; ok {
+		return sid
+	}
+	e.symbols = append(e.symbols, name)
+	sid := len(systemSymbols) + len(e.symbols)
+	e.sids[name] = sid
+	return sid
+}
+
+// symbolTable returns the encoding of $ion_symbol_table::{symbols:[...]} for the local symbols.
+func (e *binaryEncoder) symbolTable() []byte {
+	var list bytes.Buffer
+	for _, name := range e.symbols {
+		list.Write(typed(binaryString, []byte(name)))
+	}
+	var fields bytes.Buffer
+	fields.Write(varUInt(sidSymbols))
+	fields.Write(typed(binaryList, list.Bytes()))
+	return annotated([]int{sidSymbolTable}, typed(binaryStruct, fields.Bytes()))
+}
+
+func (e *binaryEncoder) encode(v Value) ([]byte, error) {
+	code, ok := binaryTypeCodes[v.Type]
+	if !ok {
+		return nil, fmt.Errorf("Cannot encode value of type %v as binary Ion", v.Type)
+	}
+	var b []byte
+	if v.IsNull() {
+		b = []byte{code | 0x0F}
+	} else {
+		var err error
+		if b, err = e.encodeContent(v); err != nil {
+			return nil, err
+		}
+	}
+	if len(v.Annotations) == 0 {
+		return b, nil
+	}
+	sids := make([]int, len(v.Annotations))
+	for i, anno := range v.Annotations {
+		sids[i] = e.sid(anno)
+	}
+	return annotated(sids, b), nil
+}
+
+func (e *binaryEncoder) encodeContent(v Value) ([]byte, error) {
+	switch v.Type {
+	case BoolType:
+		if v.Int == 0 {
+			return []byte{binaryBool}, nil
+		}
+		return []byte{binaryBool | 1}, nil
+	case IntType:
+		if v.BigInt != nil {
+			magnitude := new(big.Int).Abs(v.BigInt).Bytes()
+			if v.BigInt.Sign() < 0 {
+				return typed(binaryNegInt, magnitude), nil
+			}
+			return typed(binaryPosInt, magnitude), nil
+		}
+		if v.Int < 0 {
+			return typed(binaryNegInt, uintBytes(uint64(-(v.Int+1))+1)), nil
+		}
+		return typed(binaryPosInt, uintBytes(uint64(v.Int))), nil
+	case FloatType:
+		b := make([]byte, 8)
+		bits := math.Float64bits(v.Float)
+		for i := 7; i >= 0; i-- {
+			b[i] = byte(bits)
+			bits >>= 8
+		}
+		return typed(binaryFloat, b), nil
+	case DecimalType:
+		d := v.Decimal
+		if d.Coefficient.Sign() == 0 && d.Exponent == 0 && !d.NegativeZero {
+			return []byte{binaryDecimal}, nil
+		}
+		b := varInt(int64(d.Exponent), false)
+		b = append(b, signedMagnitude(d.Coefficient, d.NegativeZero)...)
+		return typed(binaryDecimal, b), nil
+	case TimestampType:
+		return typed(binaryTimestamp, timestampBytes(v.Time, v.Precision)), nil
+	case SymbolType:
+		if sid, ok := v.SID(); ok {
+			//local SIDs are assigned as the value is encoded, so only system SIDs have a fixed meaning
+			if sid > len(systemSymbols) {
+				return nil, fmt.Errorf("Cannot encode symbol ID %s as binary Ion: only the system symbols, up to $%d, have known IDs", v.Text, len(systemSymbols))
+			}
+			return typed(binarySymbol, uintBytes(uint64(sid))), nil
+		}
+		return typed(binarySymbol, uintBytes(uint64(e.sid(v.Text)))), nil
+	case StringType:
+		return typed(binaryString, []byte(v.Text)), nil
+	case ClobType:
+		return typed(binaryClob, v.Bytes), nil
+	case BlobType:
+		return typed(binaryBlob, v.Bytes), nil
+	case ListType, SexpType:
+		var buf bytes.Buffer
+		for _, item := range v.Sequence {
+			b, err := e.encode(item)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(b)
+		}
+		return typed(binaryTypeCodes[v.Type], buf.Bytes()), nil
+	case StructType:
+		var buf bytes.Buffer
+		for _, field := range v.Struct {
+			buf.Write(varUInt(uint64(e.sid(field.Name))))
+			b, err := e.encode(field.Value)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(b)
+		}
+		return typed(binaryStruct, buf.Bytes()), nil
+	}
+	return nil, fmt.Errorf("Cannot encode value of type %v as binary Ion", v.Type)
+}
+
+// typed prefixes content with a type descriptor, using the length nibble when the content is short
+// enough and a VarUInt length after the descriptor otherwise.
+func typed(code byte, content []byte) []byte {
+	var b []byte
+	if len(content) < 14 {
+		b = []byte{code | byte(len(content))}
+	} else {
+		b = append([]byte{code | 14}, varUInt(uint64(len(content)))...)
+	}
+	return append(b, content...)
+}
+
+// annotated wraps an encoded value with the annotations given by their SIDs.
+func annotated(sids []int, value []byte) []byte {
+	var annos []byte
+	for _, sid := range sids {
+		annos = append(annos, varUInt(uint64(sid))...)
+	}
+	content := append(varUInt(uint64(len(annos))), annos...)
+	return typed(binaryAnnotation, append(content, value...))
+}
+
+// varUInt encodes n in 7-bit groups, most significant first, with the high bit marking the last byte.
+func varUInt(n uint64) []byte {
+	b := []byte{byte(n&0x7F) | 0x80}
+	for n >>= 7; n != 0; n >>= 7 {
+		b = append([]byte{byte(n & 0x7F)}, b...)
+	}
+	return b
+}
+
+// varInt encodes n like a VarUInt, with a sign bit in the first byte. Setting negative with n of
+// zero encodes negative zero, which the unknown timestamp offset needs.
+func varInt(n int64, negative bool) []byte {
+	if n < 0 {
+		negative = true
+		n = -n
+	}
+	m := uint64(n)
+	b := []byte{byte(m&0x7F) | 0x80}
+	for m >>= 7; m != 0; m >>= 7 {
+		b = append([]byte{byte(m & 0x7F)}, b...)
+	}
+	if b[0]&0x40 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	if negative {
+		b[0] |= 0x40
+	}
+	return b
+}
+
+// uintBytes returns the big-endian bytes of n with no leading zero bytes, so zero is empty.
+func uintBytes(n uint64) []byte {
+	var b []byte
+	for ; n != 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return b
+}
+
+// signedMagnitude encodes an Int field: big-endian magnitude bytes with the sign in the high bit.
+func signedMagnitude(n *big.Int, negativeZero bool) []byte {
+	b := new(big.Int).Abs(n).Bytes()
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	if n.Sign() < 0 || negativeZero {
+		b[0] |= 0x80
+	}
+	return b
+}
+
+// timestampBytes encodes the content of a timestamp with the fields its precision calls for. Times
+// are written in UTC with their offset in minutes; dates have an unknown offset.
+func timestampBytes(t time.Time, precision TimestampPrecision) []byte {
+	var b []byte
+	if precision < MinutePrecision || t.Location() == unknownOffset {
+		b = varInt(0, true)
+	} else {
+		_, offset := t.Zone()
+		b = varInt(int64(offset/60), false)
+	}
+	if precision >= MinutePrecision {
+		t = t.UTC()
+	}
+	b = append(b, varUInt(uint64(t.Year()))...)
+	if precision >= MonthPrecision {
+		b = append(b, varUInt(uint64(t.Month()))...)
+	}
+	if precision >= DayPrecision {
+		b = append(b, varUInt(uint64(t.Day()))...)
+	}
+	if precision >= MinutePrecision {
+		b = append(b, varUInt(uint64(t.Hour()))...)
+		b = append(b, varUInt(uint64(t.Minute()))...)
+	}
+	if precision >= SecondPrecision {
+		b = append(b, varUInt(uint64(t.Second()))...)
+	}
+	if digits := int(precision - SecondPrecision); digits > 0 {
+		fraction := big.NewInt(int64(t.Nanosecond()))
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(digits-9))), nil)
+		if digits < 9 {
+			fraction.Quo(fraction, scale)
+		} else {
+			fraction.Mul(fraction, scale)
+		}
+		b = append(b, varInt(int64(-digits), false)...)
+		if fraction.Sign() != 0 {
+			b = append(b, signedMagnitude(fraction, false)...)
+		}
+	}
+	return b
+}
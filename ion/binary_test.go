@@ -0,0 +1,61 @@
+package ion
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"null", "0f"},
+		{"null.int", "2f"},
+		{"true", "11"},
+		{"false", "10"},
+		{"0", "20"},
+		{"1", "21 01"},
+		{"-1", "31 01"},
+		{"256", "22 01 00"},
+		{"1e0", "48 3f f0 00 00 00 00 00 00"},
+		{`"hi"`, "82 68 69"},
+		{`"fourteen bytes"`, "8e 8e 66 6f 75 72 74 65 65 6e 20 62 79 74 65 73"},
+		{"[1, 2]", "b4 21 01 21 02"},
+		{"(1)", "c2 21 01"},
+		{"[]", "b0"},
+		{"{a: 1}", "e7 81 83 d4 87 b2 81 61 d3 8a 21 01"},
+		{"a", "e7 81 83 d4 87 b2 81 61 71 0a"},
+		{"a::1", "e7 81 83 d4 87 b2 81 61 e4 81 8a 21 01"},
+		{"{a: a, b: a}", "e9 81 83 d6 87 b4 81 61 81 62 d6 8a 71 0a 8b 71 0a"},
+		{"name", "71 04"},
+		{"$4", "71 04"},
+		{"$9", "71 09"},
+		{"[$0, a]", "e7 81 83 d4 87 b2 81 61 b3 70 71 0a"},
+	}
+	for _, test := range tests {
+		b, err := mustParse(t, test.src).MarshalBinary()
+		if err != nil {
+			t.Errorf("MarshalBinary(%s): %v", test.src, err)
+			continue
+		}
+		if got, want := fmt.Sprintf("% x", b), "e0 01 00 ea "+test.want; got != want {
+			t.Errorf("MarshalBinary(%s):\ngot  %s\nwant %s", test.src, got, want)
+		}
+	}
+}
+
+func TestMarshalBinaryErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		//$10 would collide with the first local symbol, a
+		{"[$10, a]", "Cannot encode symbol ID $10 as binary Ion: only the system symbols, up to $9, have known IDs"},
+		{"$123", "Cannot encode symbol ID $123 as binary Ion: only the system symbols, up to $9, have known IDs"},
+	}
+	for _, test := range tests {
+		_, err := mustParse(t, test.src).MarshalBinary()
+		if got := errorString(err); got != test.err {
+			t.Errorf("MarshalBinary(%s): got error %q, want %q", test.src, got, test.err)
+		}
+	}
+}
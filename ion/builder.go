@@ -0,0 +1,77 @@
+package ion
+
+import (
+	"math/big"
+)
+
+// The functions here build values in code, as a shorter alternative to Value literals. They
+// produce the same values the parser does, so NewStruct().Field("a", Int(1)) is Equal to the
+// parsed {a: 1}.
+
+// Null returns a null value.
+func Null() Value {
+	return Value{Type: NullType}
+}
+
+// Bool returns a bool value.
+func Bool(b bool) Value {
+	v := Value{Type: BoolType}
+	if b {
+		v.Int = 1
+	}
+	return v
+}
+
+// Int returns an int value.
+func Int(n int64) Value {
+	return Value{Type: IntType, Int: n}
+}
+
+// BigInt returns an int value of any size. One that fits in an int64 is stored in Int, as the
+// parser does, rather than in BigInt.
+func BigInt(n *big.Int) Value {
+	if n.IsInt64() {
+		return Int(n.Int64())
+	}
+	return Value{Type: IntType, BigInt: new(big.Int).Set(n)}
+}
+
+// Str returns a string value.
+func Str(s string) Value {
+	return Value{Type: StringType, Text: s}
+}
+
+// Sym returns a symbol value.
+func Sym(s string) Value {
+	return Value{Type: SymbolType, Text: s}
+}
+
+// NewList returns a list of the given items.
+func NewList(items ...Value) Value {
+	return Value{Type: ListType, Sequence: append(make([]Value, 0, len(items)), items...)}
+}
+
+// NewSexp returns an s-expression of the given items.
+func NewSexp(items ...Value) Value {
+	return Value{Type: SexpType, Sequence: append(make([]Value, 0, len(items)), items...)}
+}
+
+// NewStruct returns an empty struct, to which fields can be added with Field.
+func NewStruct() Value {
+	return Value{Type: StructType, Struct: make([]Field, 0)}
+}
+
+// Field returns a copy of the struct with a field added after its existing fields. It is meant for
+// chaining, as in NewStruct().Field("a", Int(1)).Field("b", Str("x")).
+func (v Value) Field(name string, val Value) Value {
+	fields := make([]Field, len(v.Struct), len(v.Struct)+1)
+	copy(fields, v.Struct)
+	v.Struct = append(fields, Field{Name: name, Value: val})
+	return v
+}
+
+// Annotate returns a copy of the value with the annotations added after any it already has.
+func (v Value) Annotate(annotations ...string) Value {
+	v.Annotations = append(append([]string(nil), v.Annotations...), annotations...)
+	return v
+}
@@ -0,0 +1,39 @@
+package ion
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		built Value
+		src   string
+	}{
+		{Null(), "null"},
+		{Bool(true), "true"},
+		{Bool(false), "false"},
+		{Int(-42), "-42"},
+		{BigInt(huge), "123456789012345678901234567890"},
+		{BigInt(big.NewInt(5)), "5"},
+		{Str("hi"), `"hi"`},
+		{Sym("a b"), "'a b'"},
+		{NewList(), "[]"},
+		{NewList(Int(1), Str("two")), `[1, "two"]`},
+		{NewSexp(Sym("+"), Int(1), Int(2)), "(+ 1 2)"},
+		{NewStruct(), "{}"},
+		{NewStruct().Field("a", Int(1)).Field("b", NewList(Sym("x"))), "{a: 1, b: [x]}"},
+		{Int(5).Annotate("a", "b"), "a::b::5"},
+		{NewStruct().Field("f", NewStruct().Annotate("t")), "{f: t::{}}"},
+	}
+	for _, test := range tests {
+		parsed := mustParse(t, test.src)
+		if !test.built.Equal(*parsed) {
+			t.Errorf("built %s, want a value equal to %s", test.built, test.src)
+		}
+		if got, want := test.built.String(), parsed.String(); got != want {
+			t.Errorf("built %s, which prints differently from %s", got, want)
+		}
+	}
+}
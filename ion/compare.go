@@ -0,0 +1,426 @@
+package ion
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Compare defines a total ordering of values, returning -1, 0, or +1 as a is less than, equal to,
+// or greater than b. Values are ordered first by type, then by content, then by annotations. Struct
+// fields are unordered in Ion, so structs are compared as if their fields were sorted by name (and
+// by value, for duplicate names). NaN sorts before all other floats, and equal to itself.
+func Compare(a, b Value) int {
+	if a.Type != b.Type {
+		return compareInts(int64(a.Type), int64(b.Type))
+	}
+	if a.Null != b.Null {
+		if a.Null {
+			return -1
+		}
+		return 1
+	}
+	if c := compareContent(a, b); c != 0 {
+		return c
+	}
+	for i := 0; i < len(a.Annotations) && i < len(b.Annotations); i++ {
+		if c := strings.Compare(a.Annotations[i], b.Annotations[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(int64(len(a.Annotations)), int64(len(b.Annotations)))
+}
+
+// Less reports whether v sorts before other in the total ordering of Compare.
+func (v Value) Less(other Value) bool {
+	return Compare(v, other) < 0
+}
+
+// Canonical returns a copy of the value in a canonical form, for hashing or diffing: the fields
+// of every struct are sorted by name, and fields with the same name by value, in the order of
+// Compare. Details of how the value was written that do not change its meaning, the base of ints
+// and comments, are dropped. Values that differ only in those ways, or in the order of their
+// fields, have canonical forms with the same String.
+func (v Value) Canonical() Value {
+	c := v.Clone()
+	canonicalize(&c)
+	return c
+}
+
+func canonicalize(v *Value) {
+	v.IntBase = 0
+	v.Comments = nil
+	for i := range v.Sequence {
+		canonicalize(&v.Sequence[i])
+	}
+	for i := range v.Struct {
+		canonicalize(&v.Struct[i].Value)
+	}
+	sort.SliceStable(v.Struct, func(i, j int) bool {
+		a, b := v.Struct[i], v.Struct[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return Compare(a.Value, b.Value) < 0
+	})
+}
+
+func compareContent(a, b Value) int {
+	if a.Null {
+		return 0
+	}
+	switch a.Type {
+	case BoolType:
+		return compareInts(a.Int, b.Int)
+	case IntType:
+		if a.BigInt != nil || b.BigInt != nil {
+			return a.bigInt().Cmp(b.bigInt())
+		}
+		return compareInts(a.Int, b.Int)
+	case FloatType:
+		return compareFloats(a.Float, b.Float)
+	case DecimalType:
+		if c := a.Decimal.Cmp(b.Decimal); c != 0 {
+			return c
+		}
+		return compareInts(int64(b.Decimal.Exponent), int64(a.Decimal.Exponent))
+	case StringType:
+		return strings.Compare(a.Text, b.Text)
+	case SymbolType:
+		if c := strings.Compare(a.Text, b.Text); c != 0 || a.SymbolID == b.SymbolID {
+			return c
+		} else if a.SymbolID {
+			return 1
+		}
+		return -1
+	case BlobType, ClobType:
+		return bytes.Compare(a.Bytes, b.Bytes)
+	case TimestampType:
+		if c := a.Time.Compare(b.Time); c != 0 {
+			return c
+		}
+		return compareInts(int64(a.Precision), int64(b.Precision))
+	case ListType, SexpType:
+		return compareSequences(a.Sequence, b.Sequence)
+	case StructType:
+		fa, fb := sortedFields(a.Struct), sortedFields(b.Struct)
+		for i := 0; i < len(fa) && i < len(fb); i++ {
+			if c := strings.Compare(fa[i].Name, fb[i].Name); c != 0 {
+				return c
+			}
+			if c := Compare(fa[i].Value, fb[i].Value); c != 0 {
+				return c
+			}
+		}
+		return compareInts(int64(len(fa)), int64(len(fb)))
+	}
+	return 0
+}
+
+func compareInts(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareSequences(a, b []Value) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(int64(len(a)), int64(len(b)))
+}
+
+// sortedFields returns a copy of the fields ordered by name, and by value for duplicate names.
+func sortedFields(fields []Field) []Field {
+	sorted := make([]Field, len(fields))
+	copy(sorted, fields)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return Compare(sorted[i].Value, sorted[j].Value) < 0
+	})
+	return sorted
+}
+
+// Contains reports whether sub appears anywhere within the value: either the value itself, or any
+// element or field value nested inside it, is equal to sub (as defined by Equal, so annotations
+// must match too, and 0e0 does not match -0e0).
+func (v Value) Contains(sub Value) bool {
+	if v.Equal(sub) {
+		return true
+	}
+	switch v.Type {
+	case ListType, SexpType:
+		for _, item := range v.Sequence {
+			if item.Contains(sub) {
+				return true
+			}
+		}
+	case StructType:
+		for _, field := range v.Struct {
+			if field.Value.Contains(sub) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Equal reports whether two values are equivalent in the Ion data model: the same type, the same
+// annotations in the same order, and equal content. Unlike Compare, which orders by magnitude, it
+// tells apart values that are numerically equal but distinct in Ion, such as 0e0 and -0e0, or the
+// same instant written with different offsets. Struct fields are unordered, so structs are equal if
+// their fields match one for one regardless of order, duplicate names included.
+func (v Value) Equal(other Value) bool {
+	if v.Type != other.Type || v.Null != other.Null || len(v.Annotations) != len(other.Annotations) {
+		return false
+	}
+	for i, anno := range v.Annotations {
+		if anno != other.Annotations[i] {
+			return false
+		}
+	}
+	if v.Null {
+		return true
+	}
+	switch v.Type {
+	case BoolType:
+		return v.Int == other.Int
+	case IntType:
+		if v.BigInt != nil || other.BigInt != nil {
+			return v.bigInt().Cmp(other.bigInt()) == 0
+		}
+		return v.Int == other.Int
+	case FloatType:
+		return math.Float64bits(v.Float) == math.Float64bits(other.Float) || (math.IsNaN(v.Float) && math.IsNaN(other.Float))
+	case DecimalType:
+		a, b := v.Decimal, other.Decimal
+		return a.Coefficient.Cmp(b.Coefficient) == 0 && a.Exponent == b.Exponent && a.NegativeZero == b.NegativeZero
+	case StringType:
+		return v.Text == other.Text
+	case SymbolType:
+		return v.Text == other.Text && v.SymbolID == other.SymbolID
+	case BlobType, ClobType:
+		return bytes.Equal(v.Bytes, other.Bytes)
+	case TimestampType:
+		_, offset := v.Time.Zone()
+		_, otherOffset := other.Time.Zone()
+		return v.Precision == other.Precision && v.Time.Equal(other.Time) && offset == otherOffset &&
+			(v.Time.Location() == unknownOffset) == (other.Time.Location() == unknownOffset)
+	case ListType, SexpType:
+		if len(v.Sequence) != len(other.Sequence) {
+			return false
+		}
+		for i, item := range v.Sequence {
+			if !item.Equal(other.Sequence[i]) {
+				return false
+			}
+		}
+		return true
+	case StructType:
+		if len(v.Struct) != len(other.Struct) {
+			return false
+		}
+		matched := make([]bool, len(other.Struct))
+		for _, field := range v.Struct {
+			found := false
+			for j, candidate := range other.Struct {
+				if !matched[j] && field.Name == candidate.Name && field.Value.Equal(candidate.Value) {
+					matched[j], found = true, true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// StructDiff compares two structs field by field, as when auditing one config against another. It
+// returns a struct of the fields only in a, a struct of the fields only in b, and a struct of the
+// fields in both whose values differ, each of which holds {a: value in a, b: value in b}. Values
+// are compared with Equal. If a name occurs more than once, the nth field with that name in a is
+// paired with the nth in b. It is an error if either value is not a struct.
+func StructDiff(a, b Value) (onlyInA, onlyInB, changed Value, err error) {
+	for _, v := range []Value{a, b} {
+		if v.Type != StructType || v.Null {
+			return Value{}, Value{}, Value{}, fmt.Errorf("Cannot diff a value of type %v, expected a struct", v.Type)
+		}
+	}
+	onlyInA, onlyInB, changed = NewStruct(), NewStruct(), NewStruct()
+	seen := make(map[string]int)
+	for _, field := range a.Struct {
+		other, ok := nthField(b.Struct, field.Name, seen[field.Name])
+		seen[field.Name]++
+		if !ok {
+			onlyInA.Struct = append(onlyInA.Struct, field)
+		} else if !field.Value.Equal(other.Value) {
+			diff := NewStruct().Field("a", field.Value).Field("b", other.Value)
+			changed.Struct = append(changed.Struct, Field{Name: field.Name, Value: diff})
+		}
+	}
+	seen = make(map[string]int)
+	for _, field := range b.Struct {
+		if _, ok := nthField(a.Struct, field.Name, seen[field.Name]); !ok {
+			onlyInB.Struct = append(onlyInB.Struct, field)
+		}
+		seen[field.Name]++
+	}
+	return onlyInA, onlyInB, changed, nil
+}
+
+// nthField returns the field that is the nth, counting from 0, with the given name.
+func nthField(fields []Field, name string, n int) (Field, bool) {
+	for _, field := range fields {
+		if field.Name == name {
+			if n == 0 {
+				return field, true
+			}
+			n--
+		}
+	}
+	return Field{}, false
+}
+
+// DiffKind is how a value differs between the two values given to Diff.
+type DiffKind int
+
+const (
+	Added   DiffKind = iota // only in b
+	Removed                 // only in a
+	Changed                 // in both, with different values
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	}
+	return "changed"
+}
+
+// Difference is one place where two values differ, as reported by Diff.
+type Difference struct {
+	Path []string //the struct field names and sequence indices, written as "[0]", as Walk gives them
+	Kind DiffKind
+	A    Value //the value in a, unless Kind is Added
+	B    Value //the value in b, unless Kind is Removed
+}
+
+// String describes the difference on one line, as in a.b[2]: changed 1 to 2.
+func (d Difference) String() string {
+	var path strings.Builder
+	for _, step := range d.Path {
+		if path.Len() > 0 && !strings.HasPrefix(step, "[") {
+			path.WriteByte('.')
+		}
+		path.WriteString(step)
+	}
+	if path.Len() == 0 {
+		path.WriteString("(root)")
+	}
+	switch d.Kind {
+	case Added:
+		return fmt.Sprintf("%s: added %v", path.String(), d.B)
+	case Removed:
+		return fmt.Sprintf("%s: removed %v", path.String(), d.A)
+	}
+	return fmt.Sprintf("%s: changed %v to %v", path.String(), d.A, d.B)
+}
+
+// Diff compares two values, descending into structs by field name and into lists and sexps by
+// index, and returns every place where they differ, in the order of a, with anything only in b
+// after what is in both. Values of different types, or with different annotations, are reported
+// as changed as a whole rather than descended into. As with StructDiff, if a name occurs more
+// than once in a struct, the nth field with that name in a is paired with the nth in b. Values
+// that are Equal have no differences.
+func Diff(a, b Value) []Difference {
+	var diffs []Difference
+	diff(nil, a, b, &diffs)
+	return diffs
+}
+
+func diff(path []string, a, b Value, diffs *[]Difference) {
+	//each child's path gets its own array, so the paths of the differences are not shared
+	path = path[:len(path):len(path)]
+	container := a.Type == StructType || a.Type == ListType || a.Type == SexpType
+	if a.Type != b.Type || a.Null || b.Null || !container || !equalAnnotations(a, b) {
+		if !a.Equal(b) {
+			*diffs = append(*diffs, Difference{Path: path, Kind: Changed, A: a, B: b})
+		}
+		return
+	}
+	if a.Type == StructType {
+		seen := make(map[string]int)
+		for _, field := range a.Struct {
+			other, ok := nthField(b.Struct, field.Name, seen[field.Name])
+			seen[field.Name]++
+			if ok {
+				diff(append(path, field.Name), field.Value, other.Value, diffs)
+			} else {
+				*diffs = append(*diffs, Difference{Path: append(path, field.Name), Kind: Removed, A: field.Value})
+			}
+		}
+		seen = make(map[string]int)
+		for _, field := range b.Struct {
+			if _, ok := nthField(a.Struct, field.Name, seen[field.Name]); !ok {
+				*diffs = append(*diffs, Difference{Path: append(path, field.Name), Kind: Added, B: field.Value})
+			}
+			seen[field.Name]++
+		}
+		return
+	}
+	for i := 0; i < len(a.Sequence) || i < len(b.Sequence); i++ {
+		step := append(path, "["+strconv.Itoa(i)+"]")
+		switch {
+		case i >= len(b.Sequence):
+			*diffs = append(*diffs, Difference{Path: step, Kind: Removed, A: a.Sequence[i]})
+		case i >= len(a.Sequence):
+			*diffs = append(*diffs, Difference{Path: step, Kind: Added, B: b.Sequence[i]})
+		default:
+			diff(step, a.Sequence[i], b.Sequence[i], diffs)
+		}
+	}
+}
+
+func equalAnnotations(a, b Value) bool {
+	if len(a.Annotations) != len(b.Annotations) {
+		return false
+	}
+	for i := range a.Annotations {
+		if a.Annotations[i] != b.Annotations[i] {
+			return false
+		}
+	}
+	return true
+}
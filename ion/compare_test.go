@@ -0,0 +1,211 @@
+package ion
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContains(t *testing.T) {
+	doc := `{name: "x", items: [1, {id: 42, tags: (a b)}, [3, [4]]], meta: ann::{k: v}, at: (0e0 2020-01-01T00:00Z)}`
+	tests := []struct {
+		sub  string
+		want bool
+	}{
+		{"42", true},
+		{`"x"`, true},
+		{"4", true},
+		{"[4]", true},
+		{"(a b)", true},
+		{"b", true},
+		{"{id: 42, tags: (a b)}", true},
+		{"{tags: (a b), id: 42}", true},
+		{"ann::{k: v}", true},
+		{"{k: v}", false},
+		{"43", false},
+		{"id", false},
+		{"[3]", false},
+		{`"y"`, false},
+		{"0e0", true},
+		{"-0e0", false},
+		{"2020-01-01T00:00Z", true},
+		{"2020-01-01T01:00+01:00", false},
+	}
+	v := mustParse(t, doc)
+	for _, test := range tests {
+		if got := v.Contains(*mustParse(t, test.sub)); got != test.want {
+			t.Errorf("Contains(%s): got %v, want %v", test.sub, got, test.want)
+		}
+	}
+	if !v.Contains(*v) {
+		t.Errorf("a document does not contain itself")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"1", "1", true},
+		{"1", "1.0", false},
+		{"1", "1e0", false},
+		{"1.0", "1.00", false},
+		{"0e0", "-0e0", false},
+		{"nan", "nan", true},
+		{"a", `"a"`, false},
+		{"a::1", "a::1", true},
+		{"a::b::1", "b::a::1", false},
+		{"a::1", "1", false},
+		{"null", "null.int", false},
+		{"null.int", "null.int", true},
+		{"[1, 2]", "[2, 1]", false},
+		{"[1, 2]", "(1 2)", false},
+		{"{a: 1, b: 2}", "{b: 2, a: 1}", true},
+		{"{a: 1, a: 2}", "{a: 2, a: 1}", true},
+		{"{a: 1, a: 1}", "{a: 1}", false},
+		{"{a: 1, a: 1, a: 2}", "{a: 1, a: 2, a: 2}", false},
+		{"{a: {x: 1, y: [z]}}", "{a: {y: [z], x: 1}}", true},
+		{"2023-01-02T00:00Z", "2023-01-02T00:00Z", true},
+		{"2023-01-02T00:00Z", "2023-01-02T01:00+01:00", false},
+		{"{{aGk=}}", "{{aGk=}}", true},
+	}
+	for _, test := range tests {
+		a, b := mustParse(t, test.a), mustParse(t, test.b)
+		if got := a.Equal(*b); got != test.equal {
+			t.Errorf("%s.Equal(%s): got %v, want %v", test.a, test.b, got, test.equal)
+		}
+		if got := b.Equal(*a); got != test.equal {
+			t.Errorf("%s.Equal(%s): got %v, want %v", test.b, test.a, got, test.equal)
+		}
+	}
+}
+
+func TestStructDiff(t *testing.T) {
+	tests := []struct {
+		a, b                     string
+		onlyInA, onlyInB, change string
+	}{
+		{
+			`{host: "a", port: 80, debug: true, tags: [x]}`,
+			`{host: "b", port: 80, tags: [x, y], timeout: 30}`,
+			"{debug: true}", "{timeout: 30}", `{host: {a: "a", b: "b"}, tags: {a: [x], b: [x, y]}}`,
+		},
+		{"{a: 1}", "{a: 1}", "{}", "{}", "{}"},
+		{"{}", "{a: 1}", "{}", "{a: 1}", "{}"},
+		{"{a: 1, a: 2}", "{a: 1}", "{a: 2}", "{}", "{}"},
+		{"{a: 1, a: 2}", "{a: 1, a: 3}", "{}", "{}", "{a: {a: 2, b: 3}}"},
+		{"{a: {x: 1, y: 2}}", "{a: {y: 2, x: 1}}", "{}", "{}", "{}"},
+	}
+	for _, test := range tests {
+		onlyInA, onlyInB, changed, err := StructDiff(*mustParse(t, test.a), *mustParse(t, test.b))
+		if err != nil {
+			t.Errorf("StructDiff(%s, %s): %v", test.a, test.b, err)
+			continue
+		}
+		if got := onlyInA.String(); got != test.onlyInA {
+			t.Errorf("StructDiff(%s, %s): got only in a %s, want %s", test.a, test.b, got, test.onlyInA)
+		}
+		if got := onlyInB.String(); got != test.onlyInB {
+			t.Errorf("StructDiff(%s, %s): got only in b %s, want %s", test.a, test.b, got, test.onlyInB)
+		}
+		if got := changed.String(); got != test.change {
+			t.Errorf("StructDiff(%s, %s): got changed %s, want %s", test.a, test.b, got, test.change)
+		}
+	}
+	if _, _, _, err := StructDiff(*mustParse(t, "{}"), *mustParse(t, "[1]")); errorString(err) != "Cannot diff a value of type list, expected a struct" {
+		t.Errorf("StructDiff of a list: got error %v", err)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		srcs []string
+		want string
+	}{
+		{[]string{"{b: 1, a: 2}", "{a: 2, b: 1}"}, "{a: 2, b: 1}"},
+		{[]string{"{x: {d: 1, c: [{f: 1, e: 2}]}, a: 0x10}", "{a: 16, x: {c: [{e: 2, f: 1}], d: 1}}"}, "{a: 16, x: {c: [{e: 2, f: 1}], d: 1}}"},
+		{[]string{"{a: 2, b: 0, a: 1}", "{a: 1, a: 2, b: 0}", "{b: 0, a: 2, a: 1}"}, "{a: 1, a: 2, b: 0}"},
+		{[]string{"ann::{z: (1 2), y: 'q'}"}, "ann::{y: q, z: (1 2)}"},
+		{[]string{"[3, 1, 2]"}, "[3, 1, 2]"},
+	}
+	for _, test := range tests {
+		for _, src := range test.srcs {
+			v := mustParse(t, src)
+			if got := v.Canonical().String(); got != test.want {
+				t.Errorf("%s.Canonical(): got %s, want %s", src, got, test.want)
+			}
+		}
+	}
+	v := mustParse(t, "{b: 1, a: 2}")
+	v.Canonical()
+	if got := v.String(); got != "{b: 1, a: 2}" {
+		t.Errorf("Canonical changed the original: got %s", got)
+	}
+}
+
+func TestLess(t *testing.T) {
+	//types sort in the order of the Type constants
+	sorted := []string{"null", "false", "true", "null.int", "-5", "3", "1e0", `"a"`, "a", "b", "{}", "[]", "[1]", "(1)", "2020-01-01T", "1.5"}
+	for i, a := range sorted {
+		for j, b := range sorted {
+			if got := mustParse(t, a).Less(*mustParse(t, b)); got != (i < j) {
+				t.Errorf("%s.Less(%s): got %v, want %v", a, b, got, i < j)
+			}
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []string
+	}{
+		{
+			`{name: "svc", db: {host: a, port: 5432}, tags: [x, y, z], owner: ann, v: 1}`,
+			`{name: "svc", db: {host: b, port: 5432, pool: 4}, tags: [x, w], v: 1.0, extra: true}`,
+			[]string{
+				"db.host: changed a to b",
+				"db.pool: added 4",
+				"tags[1]: changed y to w",
+				"tags[2]: removed z",
+				"owner: removed ann",
+				"v: changed 1 to 1.0",
+				"extra: added true",
+			},
+		},
+		{"{a: 1}", "{a: 1}", nil},
+		{"{a: 1, b: 2}", "{b: 2, a: 1}", nil},
+		{"1", "2", []string{"(root): changed 1 to 2"}},
+		{"[1, [2, 3]]", "[1, [2], 4]", []string{"[1][1]: removed 3", "[2]: added 4"}},
+		{"(a b)", "[a, b]", []string{"(root): changed (a b) to [a, b]"}},
+		{"x::{a: 1}", "y::{a: 2}", []string{"(root): changed x::{a: 1} to y::{a: 2}"}},
+		{"{a: null.struct}", "{a: {}}", []string{"a: changed null.struct to {}"}},
+		{"{a: 1, a: 2}", "{a: 1, a: 3, a: 4}", []string{"a: changed 2 to 3", "a: added 4"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, d := range Diff(*mustParse(t, test.a), *mustParse(t, test.b)) {
+			got = append(got, d.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Diff(%s, %s):\ngot  %q\nwant %q", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestDiffFields(t *testing.T) {
+	diffs := Diff(*mustParse(t, "{a: [1, {b: 2}]}"), *mustParse(t, "{a: [1, {b: 3}], c: 4}"))
+	want := []Difference{
+		{Path: []string{"a", "[1]", "b"}, Kind: Changed, A: Int(2), B: Int(3)},
+		{Path: []string{"c"}, Kind: Added, B: Int(4)},
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("got %v, want %v", diffs, want)
+	}
+	for i, d := range diffs {
+		w := want[i]
+		if !reflect.DeepEqual(d.Path, w.Path) || d.Kind != w.Kind || !d.A.Equal(w.A) || !d.B.Equal(w.B) {
+			t.Errorf("difference %d: got %#v, want %#v", i, d, w)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ion
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an arbitrary-precision decimal number, Coefficient × 10^Exponent. Unlike a float it
+// keeps the precision it was written with, so 1.00 (100 × 10^-2) is distinct from 1.0 (10 × 10^-1).
+// Ion also distinguishes -0.0 from 0.0, which a big.Int coefficient cannot, so NegativeZero marks a
+// zero coefficient as negative.
+type Decimal struct {
+	Coefficient  *big.Int
+	Exponent     int
+	NegativeZero bool
+}
+
+// ParseDecimal parses the text of a decimal, such as "1.00", "-3.25", or "12.5d-3".
+func ParseDecimal(lit string) (*Decimal, error) {
+	bad := fmt.Errorf("Cannot parse decimal: %q", lit)
+	mantissa, exponent := lit, 0
+	if i := strings.IndexAny(lit, "dD"); i >= 0 {
+		exp, err := strconv.Atoi(lit[i+1:])
+		if err != nil {
+			return nil, bad
+		}
+		mantissa, exponent = lit[:i], exp
+	}
+	if i := strings.Index(mantissa, "."); i >= 0 {
+		exponent -= len(mantissa) - i - 1
+		mantissa = mantissa[:i] + mantissa[i+1:]
+	}
+	coefficient, ok := new(big.Int).SetString(mantissa, 10)
+	if !ok {
+		return nil, bad
+	}
+	negativeZero := coefficient.Sign() == 0 && strings.HasPrefix(mantissa, "-")
+	return &Decimal{Coefficient: coefficient, Exponent: exponent, NegativeZero: negativeZero}, nil
+}
+
+// String renders the decimal in Ion text, preserving its precision.
+func (d *Decimal) String() string {
+	digits := new(big.Int).Abs(d.Coefficient).String()
+	sign := ""
+	if d.Coefficient.Sign() < 0 || d.NegativeZero {
+		sign = "-"
+	}
+	switch {
+	case d.Exponent == 0:
+		//not "5.", which Ion does not allow
+		return sign + digits + "d0"
+	case d.Exponent > 0:
+		return fmt.Sprintf("%s%sd%d", sign, digits, d.Exponent)
+	}
+	point := len(digits) + d.Exponent
+	if point > 0 {
+		return sign + digits[:point] + "." + digits[point:]
+	}
+	return sign + "0." + strings.Repeat("0", -point) + digits
+}
+
+// Rat returns the exact value of the decimal as a rational number.
+func (d *Decimal) Rat() *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(d.Exponent))), nil)
+	r := new(big.Rat).SetInt(d.Coefficient)
+	if d.Exponent >= 0 {
+		return r.Mul(r, new(big.Rat).SetInt(scale))
+	}
+	return r.Quo(r, new(big.Rat).SetInt(scale))
+}
+
+// Float64 returns the nearest float64 to the decimal's value.
+func (d *Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// Cmp compares the values of two decimals, returning -1, 0, or +1. Decimals with the same value
+// but different precision, such as 1.0 and 1.00, compare equal.
+func (d *Decimal) Cmp(other *Decimal) int {
+	return d.Rat().Cmp(other.Rat())
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+package ion
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecimals(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"1.00", "1.00"},
+		{"0.0", "0.0"},
+		{"-0.0", "-0.0"},
+		{"19.99", "19.99"},
+		{"1.5d3", "15d2"},
+		{"1.5D-3", "0.0015"},
+		{"5d0", "5d0"},
+		{"123456789012345678901234567890.123456789", "123456789012345678901234567890.123456789"},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if v.Type != DecimalType {
+			t.Errorf("Parse(%q): got %s, want a decimal", test.src, v.Type)
+		} else if got := v.String(); got != test.want {
+			t.Errorf("Parse(%q).String(): got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		lit         string
+		coefficient string
+		exponent    int
+		err         string
+	}{
+		{"1.00", "100", -2, ""},
+		{"1.0", "10", -1, ""},
+		{"-3.25", "-325", -2, ""},
+		{"12.5d-3", "125", -4, ""},
+		{"7d2", "7", 2, ""},
+		{"1.5dx", "", 0, `Cannot parse decimal: "1.5dx"`},
+	}
+	for _, test := range tests {
+		d, err := ParseDecimal(test.lit)
+		if got := errorString(err); got != test.err {
+			t.Errorf("ParseDecimal(%q): got error %q, want %q", test.lit, got, test.err)
+			continue
+		}
+		if err == nil && (d.Coefficient.String() != test.coefficient || d.Exponent != test.exponent) {
+			t.Errorf("ParseDecimal(%q): got %s×10^%d, want %s×10^%d", test.lit, d.Coefficient, d.Exponent, test.coefficient, test.exponent)
+		}
+	}
+}
+
+func TestDecimalExponent(t *testing.T) {
+	tests := []struct {
+		src  string
+		typ  Type
+		want string
+	}{
+		{"1d3", DecimalType, "1d3"},
+		{"6.5d-2", DecimalType, "0.065"},
+		{"1d0", DecimalType, "1d0"},
+		{"1.0", DecimalType, "1.0"},
+		{"1D+2", DecimalType, "1d2"},
+		{"-2.5d1", DecimalType, "-25d0"},
+		{"1e0", FloatType, "1e0"},
+		{"6.5e-2", FloatType, "0.065e0"},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if v.Type != test.typ || v.String() != test.want {
+			t.Errorf("Parse(%s): got %s %s, want %s %s", test.src, v.Type, v, test.typ, test.want)
+		}
+	}
+	for _, src := range []string{"1d", "1d+", "1dd2", "1d2.5"} {
+		if _, err := Parse(strings.NewReader(src)); err == nil {
+			t.Errorf("Parse(%s): expected an error", src)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package ion
+
+import (
+	"io"
+)
+
+// EventHandler receives the parts of values as ParseEvents reads them, without any tree of values
+// being built. A value with annotations is preceded by OnAnnotations. A struct's fields are each
+// reported by OnFieldName followed by the field's value. Containers are reported by their Begin
+// and End methods, with their contents in between, and everything else by OnScalar, whose Value
+// also carries the scalar's annotations.
+type EventHandler interface {
+	OnAnnotations(annotations []string)
+	OnFieldName(name string)
+	OnScalar(v Value)
+	OnStructBegin()
+	OnStructEnd()
+	OnListBegin()
+	OnListEnd()
+	OnSexpBegin()
+	OnSexpEnd()
+}
+
+// ParseEvents parses every top-level value in r, reporting each one's parts to handler as they are
+// read. Since no values are kept, it can process input of any size in little memory.
+func ParseEvents(r io.Reader, handler EventHandler) error {
+	p := NewParser("", r)
+	if err := p.parseAllEvents(handler); err != nil {
+		return p.syntaxError(err)
+	}
+	return nil
+}
+
+func (p *Parser) parseAllEvents(handler EventHandler) error {
+	for {
+		if err := p.checkContext(); err != nil {
+			return err
+		}
+		tok, lit := p.scan()
+		if tok == EOF {
+			return nil
+		}
+		if tok == COMMA || tok == COLON || p.versionMarker(tok, lit) {
+			continue
+		}
+		annotations, tok, lit, err := p.parseAnnotations(tok, lit)
+		if err != nil {
+			return err
+		}
+		if annotations != nil {
+			handler.OnAnnotations(annotations)
+		}
+		if isOpener(tok) {
+			if err := p.parseEvents(tok, handler); err != nil {
+				return err
+			}
+			continue
+		}
+		val, err := p.parseAnnotatedScalar(annotations, tok, lit)
+		if err != nil {
+			return err
+		}
+		handler.OnScalar(*val)
+	}
+}
+
+// fieldNameAnnotationsHandler is implemented by handlers that keep the annotations on field names
+// read with the FieldNameAnnotations option. They are reported just after the name itself.
+type fieldNameAnnotationsHandler interface {
+	onFieldNameAnnotations(annotations []string)
+}
+
+// commentsHandler is implemented by handlers that keep the comments read with the KeepComments
+// option. They are reported just before the annotations of the value they precede.
+type commentsHandler interface {
+	onComments(comments []string)
+}
+
+// leading reports the comments and annotations that come before a value.
+func leading(h EventHandler, comments, annotations []string) {
+	if comments != nil {
+		if ch, ok := h.(commentsHandler); ok {
+			ch.onComments(comments)
+		}
+	}
+	if annotations != nil {
+		h.OnAnnotations(annotations)
+	}
+}
+
+func beginEvent(h EventHandler, t Type) {
+	switch t {
+	case StructType:
+		h.OnStructBegin()
+	case ListType:
+		h.OnListBegin()
+	default:
+		h.OnSexpBegin()
+	}
+}
+
+func endEvent(h EventHandler, t Type) {
+	switch t {
+	case StructType:
+		h.OnStructEnd()
+	case ListType:
+		h.OnListEnd()
+	default:
+		h.OnSexpEnd()
+	}
+}
+
+// treeBuilder is the EventHandler the parser uses to build values.
+type treeBuilder struct {
+	stack       []*Value
+	fields      []Field  //the field each open container will be added as
+	field       Field    //the field whose value comes next
+	annotations []string //for the container that begins next
+	comments    []string //for the value that comes next
+	result      *Value
+}
+
+func (b *treeBuilder) OnAnnotations(annotations []string) { b.annotations = annotations }
+func (b *treeBuilder) OnFieldName(name string)            { b.field = Field{Name: name} }
+func (b *treeBuilder) OnScalar(v Value) {
+	v.Comments, b.comments, b.annotations = b.comments, nil, nil
+	b.add(&v, b.field)
+}
+func (b *treeBuilder) onFieldNameAnnotations(annotations []string) {
+	b.field.NameAnnotations = annotations
+}
+func (b *treeBuilder) onComments(comments []string) { b.comments = comments }
+func (b *treeBuilder) OnStructBegin() {
+	b.begin(&Value{Type: StructType, Struct: make([]Field, 0)})
+}
+func (b *treeBuilder) OnListBegin() {
+	b.begin(&Value{Type: ListType, Sequence: make([]Value, 0)})
+}
+func (b *treeBuilder) OnSexpBegin() {
+	b.begin(&Value{Type: SexpType, Sequence: make([]Value, 0)})
+}
+func (b *treeBuilder) OnStructEnd() { b.end() }
+func (b *treeBuilder) OnListEnd()   { b.end() }
+func (b *treeBuilder) OnSexpEnd()   { b.end() }
+
+func (b *treeBuilder) begin(v *Value) {
+	v.Annotations, b.annotations = b.annotations, nil
+	v.Comments, b.comments = b.comments, nil
+	b.stack = append(b.stack, v)
+	b.fields = append(b.fields, b.field)
+}
+
+func (b *treeBuilder) end() {
+	n := len(b.stack) - 1
+	v, field := b.stack[n], b.fields[n]
+	b.stack, b.fields = b.stack[:n], b.fields[:n]
+	b.add(v, field)
+}
+
+func (b *treeBuilder) add(v *Value, field Field) {
+	if len(b.stack) == 0 {
+		b.result = v
+		return
+	}
+	top := b.stack[len(b.stack)-1]
+	if top.Type == StructType {
+		field.Value = *v
+		top.Struct = append(top.Struct, field)
+	} else {
+		top.Sequence = append(top.Sequence, *v)
+	}
+}
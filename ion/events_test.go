@@ -0,0 +1,79 @@
+package ion
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// eventLog records the events it is given as text.
+type eventLog struct {
+	events []string
+}
+
+func (l *eventLog) OnAnnotations(annotations []string) {
+	l.events = append(l.events, strings.Join(annotations, "::")+"::")
+}
+func (l *eventLog) OnFieldName(name string) { l.events = append(l.events, name+":") }
+func (l *eventLog) OnScalar(v Value)        { l.events = append(l.events, v.String()) }
+func (l *eventLog) OnStructBegin()          { l.events = append(l.events, "{") }
+func (l *eventLog) OnStructEnd()            { l.events = append(l.events, "}") }
+func (l *eventLog) OnListBegin()            { l.events = append(l.events, "[") }
+func (l *eventLog) OnListEnd()              { l.events = append(l.events, "]") }
+func (l *eventLog) OnSexpBegin()            { l.events = append(l.events, "(") }
+func (l *eventLog) OnSexpEnd()              { l.events = append(l.events, ")") }
+
+// fieldCounter counts struct fields by name.
+type fieldCounter struct {
+	eventLog
+	counts map[string]int
+}
+
+func (c *fieldCounter) OnFieldName(name string) { c.counts[name]++ }
+
+func TestParseEvents(t *testing.T) {
+	tests := []struct {
+		src    string
+		events []string
+	}{
+		{"1 a", []string{"1", "a"}},
+		{"{a: 1, b: [x, (+ 1)]}", []string{"{", "a:", "1", "b:", "[", "x", "(", "'+'", "1", ")", "]", "}"}},
+		{"t::{a: u::2} v::w::[]", []string{"t::", "{", "a:", "u::", "u::2", "}", "v::w::", "[", "]"}},
+		{"$ion_1_0 {}", []string{"{", "}"}},
+	}
+	for _, test := range tests {
+		var log eventLog
+		if err := ParseEvents(strings.NewReader(test.src), &log); err != nil {
+			t.Errorf("ParseEvents(%q): %v", test.src, err)
+		} else if !reflect.DeepEqual(log.events, test.events) {
+			t.Errorf("ParseEvents(%q):\ngot  %q\nwant %q", test.src, log.events, test.events)
+		}
+	}
+}
+
+func TestParseEventsCountsFields(t *testing.T) {
+	src := `{id: 1, name: "a", tags: [{id: 2}, {id: 3, name: "c"}]} {id: 4}`
+	c := &fieldCounter{counts: make(map[string]int)}
+	if err := ParseEvents(strings.NewReader(src), c); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"id": 4, "name": 2, "tags": 1}
+	if !reflect.DeepEqual(c.counts, want) {
+		t.Errorf("got field counts %v, want %v", c.counts, want)
+	}
+}
+
+func TestParseEventsErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"[1, 2}", "1:6: expected ']' to close list opened at 1:1, found '}'"},
+		{"{a 1}", "1:4: Expected ':' after struct field name \"a\", found '1'"},
+	}
+	for _, test := range tests {
+		err := ParseEvents(strings.NewReader(test.src), &eventLog{})
+		if got := errorString(err); got != test.err {
+			t.Errorf("ParseEvents(%q): got error %q, want %q", test.src, got, test.err)
+		}
+	}
+}
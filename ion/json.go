@@ -0,0 +1,225 @@
+package ion
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// JSONAnnotationsKey, if set, makes MarshalJSON keep annotations: an annotated value is written as
+// an object holding its annotations under this key and the value itself under "value". By default
+// annotations are dropped.
+var JSONAnnotationsKey = ""
+
+// MarshalJSON converts the value to JSON. Structs become objects, lists and sexps become arrays,
+// strings and symbols become strings, and ints, floats, and bools map directly. Nulls of every type
+// become null. The conversion is lossy where JSON has nothing equivalent:
+//
+//   - decimals and timestamps are written as strings of their Ion text, to keep their precision
+//   - blobs and clobs are written as base64 strings
+//   - nan and the infinities, which JSON numbers cannot express, are written as null
+//   - a symbol and a string with the same text are indistinguishable
+//   - duplicate field names are written as they are, though many JSON readers keep only the last
+func (v Value) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, v Value) error {
+	if JSONAnnotationsKey != "" && len(v.Annotations) > 0 {
+		annotations := v.Annotations
+		v.Annotations = nil
+		buf.WriteByte('{')
+		writeJSONString(buf, JSONAnnotationsKey)
+		buf.WriteByte(':')
+		b, err := json.Marshal(annotations)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteString(`,"value":`)
+		if err := writeJSON(buf, v); err != nil {
+			return err
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+	if v.IsNull() {
+		buf.WriteString("null")
+		return nil
+	}
+	switch v.Type {
+	case BoolType:
+		if v.Int == 0 {
+			buf.WriteString("false")
+		} else {
+			buf.WriteString("true")
+		}
+	case IntType:
+		if v.BigInt != nil {
+			buf.WriteString(v.BigInt.String())
+			return nil
+		}
+		b, _ := json.Marshal(v.Int)
+		buf.Write(b)
+	case FloatType:
+		if math.IsNaN(v.Float) || math.IsInf(v.Float, 0) {
+			buf.WriteString("null")
+			return nil
+		}
+		b, err := json.Marshal(v.Float)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	case DecimalType:
+		writeJSONString(buf, v.Decimal.String())
+	case TimestampType:
+		writeJSONString(buf, formatTimestamp(v.Time, v.Precision))
+	case StringType, SymbolType:
+		writeJSONString(buf, v.Text)
+	case BlobType, ClobType:
+		writeJSONString(buf, base64.StdEncoding.EncodeToString(v.Bytes))
+	case StructType:
+		buf.WriteByte('{')
+		for i, field := range v.Struct {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, field.Name)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, field.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case ListType, SexpType:
+		buf.WriteByte('[')
+		for i, item := range v.Sequence {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		return fmt.Errorf("Cannot convert value of type %v to JSON", v.Type)
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
+
+// ParseJSON parses a JSON document into a Value. Objects become structs with their keys in order,
+// arrays become lists, numbers with a fraction or exponent become floats, and other numbers become
+// ints, of any size. It returns a nil value if the input is empty, and an error if anything but
+// whitespace follows the value. Nesting is limited only by encoding/json, which rejects input nested
+// more than 10000 levels deep.
+func ParseJSON(r io.Reader) (*Value, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	//nested objects and arrays are kept on a stack, so deep input is not limited by the goroutine stack
+	type frame struct {
+		val     *Value
+		name    string
+		hasName bool
+	}
+	var stack []*frame
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			if len(stack) == 0 {
+				return nil, nil
+			}
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid JSON: %v", err)
+		}
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if name, ok := tok.(string); ok && top != nil && top.val.Type == StructType && !top.hasName {
+			top.name, top.hasName = name, true
+			continue
+		}
+		var val Value
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				stack = append(stack, &frame{val: &Value{Type: StructType, Struct: make([]Field, 0)}})
+				continue
+			case '[':
+				stack = append(stack, &frame{val: &Value{Type: ListType, Sequence: make([]Value, 0)}})
+				continue
+			}
+			val = *top.val
+			stack = stack[:len(stack)-1]
+			top = nil
+			if len(stack) > 0 {
+				top = stack[len(stack)-1]
+			}
+		case string:
+			val = Value{Type: StringType, Text: t}
+		case json.Number:
+			n, err := jsonNumber(string(t))
+			if err != nil {
+				return nil, err
+			}
+			val = *n
+		case bool:
+			val = Value{Type: BoolType}
+			if t {
+				val.Int = 1
+			}
+		case nil:
+			val = Value{Type: NullType}
+		}
+		if top == nil {
+			if _, err := dec.Token(); err != io.EOF {
+				return nil, fmt.Errorf("Invalid JSON: unexpected data after the top-level value")
+			}
+			return &val, nil
+		}
+		if top.val.Type == StructType {
+			top.val.Struct = append(top.val.Struct, Field{Name: top.name, Value: val})
+			top.hasName = false
+		} else {
+			top.val.Sequence = append(top.val.Sequence, val)
+		}
+	}
+}
+
+func jsonNumber(lit string) (*Value, error) {
+	if strings.ContainsAny(lit, ".eE") {
+		f, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid JSON number: %q", lit)
+		}
+		return &Value{Type: FloatType, Float: f}, nil
+	}
+	if n, err := strconv.ParseInt(lit, 10, 64); err == nil {
+		return &Value{Type: IntType, Int: n}, nil
+	}
+	n, ok := new(big.Int).SetString(lit, 10)
+	if !ok {
+		return nil, fmt.Errorf("Invalid JSON number: %q", lit)
+	}
+	return &Value{Type: IntType, BigInt: n}, nil
+}
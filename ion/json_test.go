@@ -0,0 +1,98 @@
+package ion
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`{a: 1, b: [true, null], c: "s", d: sym}`, `{"a":1,"b":[true,null],"c":"s","d":"sym"}`},
+		{"(a 1)", `["a",1]`},
+		{"1.50", `"1.50"`},
+		{"2.5e0", "2.5"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"2023-01-02T03:04Z", `"2023-01-02T03:04Z"`},
+		{"{{aGk=}}", `"aGk="`},
+		{`{{"hi"}}`, `"aGk="`},
+		{"[nan, +inf, -inf]", "[null,null,null]"},
+		{"[null.int, null.struct]", "[null,null]"},
+		{"ann::{a: x::1}", `{"a":1}`},
+		{`{a: 1, a: 2}`, `{"a":1,"a":2}`},
+		{`"q\"<é>"`, `"q\"\u003cé\u003e"`},
+	}
+	for _, test := range tests {
+		b, err := mustParse(t, test.src).MarshalJSON()
+		if err != nil {
+			t.Errorf("MarshalJSON(%s): %v", test.src, err)
+		} else if got := string(b); got != test.want {
+			t.Errorf("MarshalJSON(%s): got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestMarshalJSONAnnotationsKey(t *testing.T) {
+	defer func(key string) { JSONAnnotationsKey = key }(JSONAnnotationsKey)
+	JSONAnnotationsKey = "$annotations"
+	tests := []struct {
+		src, want string
+	}{
+		{"1", "1"},
+		{"a::b::1", `{"$annotations":["a","b"],"value":1}`},
+		{"{f: t::[x]}", `{"f":{"$annotations":["t"],"value":["x"]}}`},
+	}
+	for _, test := range tests {
+		b, err := mustParse(t, test.src).MarshalJSON()
+		if err != nil {
+			t.Errorf("MarshalJSON(%s): %v", test.src, err)
+		} else if got := string(b); got != test.want {
+			t.Errorf("MarshalJSON(%s): got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		src, want, err string
+	}{
+		{`{"b": 1, "a": [true, null, "s"]}`, `{b: 1, a: [true, null, "s"]}`, ""},
+		{`{"a": {}, "b": []}`, "{a: {}, b: []}", ""},
+		{"1.5", "1.5e0", ""},
+		{"1e3", "1000e0", ""},
+		{"-42", "-42", ""},
+		{"123456789012345678901234567890", "123456789012345678901234567890", ""},
+		{`"s"`, `"s"`, ""},
+		{"  [1]  \n", "[1]", ""},
+		{`{"a":1} garbage`, "", "Invalid JSON: unexpected data after the top-level value"},
+		{"1 2", "", "Invalid JSON: unexpected data after the top-level value"},
+		{"[1] [2]", "", "Invalid JSON: unexpected data after the top-level value"},
+		{`{"a": 1`, "", "Invalid JSON: unexpected EOF"},
+	}
+	for _, test := range tests {
+		v, err := ParseJSON(strings.NewReader(test.src))
+		if got := errorString(err); got != test.err {
+			t.Errorf("ParseJSON(%q): got error %q, want %q", test.src, got, test.err)
+		} else if err == nil && v.String() != test.want {
+			t.Errorf("ParseJSON(%q): got %s, want %s", test.src, v, test.want)
+		}
+	}
+	if v, err := ParseJSON(strings.NewReader("  ")); v != nil || err != nil {
+		t.Errorf("ParseJSON of empty input: got %v, %v, want nil, nil", v, err)
+	}
+}
+
+func TestParseJSONDeep(t *testing.T) {
+	const depth = 5000
+	v, err := ParseJSON(strings.NewReader(strings.Repeat("[", depth) + strings.Repeat("]", depth)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < depth; i++ {
+		v = &v.Sequence[0]
+	}
+	if v.Type != ListType || len(v.Sequence) != 0 {
+		t.Errorf("got innermost value %s, want []", v)
+	}
+}
@@ -0,0 +1,522 @@
+package ion
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	valueType = reflect.TypeOf(Value{})
+	timeType  = reflect.TypeOf(time.Time{})
+	bigType   = reflect.TypeOf(big.Int{})
+)
+
+// Marshal converts a Go value into a Value, much as encoding/json would convert it to JSON. Bools,
+// integers (including big.Int), floats, and strings become the corresponding Ion scalars, a
+// time.Time becomes a timestamp, a []byte becomes a blob, and other slices and arrays become
+// lists. Maps with string keys and structs become Ion structs; map keys are sorted so the result
+// is deterministic. Pointers and interfaces are followed, and a nil one becomes null, as does a
+// nil slice or map. A Value or *Value is copied as it is.
+//
+// Struct fields are named by an ion tag if present, and otherwise by the Go field name. The tag
+// `ion:"-"` skips a field, and the option omitempty, as in `ion:"name,omitempty"`, skips a field
+// whose value is false, 0, an empty string, or a nil or empty pointer, slice, or map. Unexported
+// fields are skipped, and the fields of an embedded struct without a tag are included as if they
+// belonged to the outer struct.
+//
+// Channels, functions, complex numbers, and maps whose keys are not strings cannot be marshaled and
+// produce an error.
+func Marshal(v interface{}) (*Value, error) {
+	val, err := marshalValue(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return &val, nil
+}
+
+func marshalValue(rv reflect.Value) (Value, error) {
+	if !rv.IsValid() {
+		return Value{Type: NullType}, nil
+	}
+	if rv.Type() == valueType {
+		return rv.Interface().(Value).Clone(), nil
+	}
+	if rv.Type() == timeType {
+		t := rv.Interface().(time.Time)
+		precision := SecondPrecision
+		if t.Nanosecond() != 0 {
+			precision += 9
+		}
+		return Value{Type: TimestampType, Time: t, Precision: precision}, nil
+	}
+	if rv.Type() == bigType {
+		n := rv.Interface().(big.Int)
+		return BigInt(&n), nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return Value{Type: NullType}, nil
+		}
+		return marshalValue(rv.Elem())
+	case reflect.Bool:
+		return Bool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return BigInt(new(big.Int).SetUint64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return Value{Type: FloatType, Float: rv.Float()}, nil
+	case reflect.String:
+		return Str(rv.String()), nil
+	case reflect.Slice:
+		if rv.IsNil() {
+			if rv.Type().Elem().Kind() == reflect.Uint8 {
+				return Value{Type: BlobType, Null: true}, nil
+			}
+			return Value{Type: ListType, Null: true}, nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return Value{Type: BlobType, Bytes: append([]byte(nil), rv.Bytes()...)}, nil
+		}
+		return marshalSequence(rv)
+	case reflect.Array:
+		return marshalSequence(rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return Value{}, fmt.Errorf("Cannot marshal map with %v keys: keys must be strings", rv.Type().Key())
+		}
+		if rv.IsNil() {
+			return Value{Type: StructType, Null: true}, nil
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		val := NewStruct()
+		for _, key := range keys {
+			item, err := marshalValue(rv.MapIndex(key))
+			if err != nil {
+				return Value{}, err
+			}
+			val.Struct = append(val.Struct, Field{Name: key.String(), Value: item})
+		}
+		return val, nil
+	case reflect.Struct:
+		val := NewStruct()
+		if err := marshalFields(rv, &val); err != nil {
+			return Value{}, err
+		}
+		return val, nil
+	}
+	return Value{}, fmt.Errorf("Cannot marshal value of type %v", rv.Type())
+}
+
+func marshalSequence(rv reflect.Value) (Value, error) {
+	val := NewList()
+	for i := 0; i < rv.Len(); i++ {
+		item, err := marshalValue(rv.Index(i))
+		if err != nil {
+			return Value{}, err
+		}
+		val.Sequence = append(val.Sequence, item)
+	}
+	return val, nil
+}
+
+// marshalFields adds the fields of a Go struct to an Ion struct, flattening untagged embedded structs.
+func marshalFields(rv reflect.Value, val *Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, omitEmpty, skip := fieldTag(sf)
+		if skip {
+			continue
+		}
+		fv := rv.Field(i)
+		if sf.Anonymous && sf.Tag.Get("ion") == "" {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+				if err := marshalFields(fv, val); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		item, err := marshalValue(fv)
+		if err != nil {
+			return fmt.Errorf("Field %s: %v", sf.Name, err)
+		}
+		val.Struct = append(val.Struct, Field{Name: name, Value: item})
+	}
+	return nil
+}
+
+// fieldTag returns the Ion field name for a Go struct field, whether it has the omitempty option,
+// and whether it should be skipped entirely.
+func fieldTag(sf reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	if sf.PkgPath != "" && !sf.Anonymous {
+		return "", false, true
+	}
+	tag := sf.Tag.Get("ion")
+	if tag == "-" {
+		return "", false, true
+	}
+	name = sf.Name
+	parts := strings.Split(tag, ",")
+	if parts[0] != "" {
+		name = parts[0]
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
+
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// Unmarshal stores the value into the Go value that target points to, the reverse of Marshal. Ion
+// struct fields are matched to Go struct fields by their ion tag, or else by a case-insensitive
+// match on the Go field name; Ion fields with no match are ignored. Ints convert to Go integers
+// and big.Int, ints, floats, and decimals to Go floats, strings and symbols to Go strings,
+// timestamps to time.Time, blobs and clobs to []byte, lists and sexps to slices and arrays, and
+// structs to Go structs and string-keyed maps. A null of any type sets the target to its zero
+// value. A target of type Value receives a copy of the value itself.
+//
+// A value that cannot be converted to its target, such as a struct into an int, is an error naming
+// the path of the field where it occurred.
+func (v Value) Unmarshal(target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Cannot unmarshal into %v: target must be a non-nil pointer", reflect.TypeOf(target))
+	}
+	return unmarshalValue(v, rv.Elem(), "")
+}
+
+func unmarshalValue(v Value, rv reflect.Value, path string) error {
+	if rv.Type() == valueType {
+		rv.Set(reflect.ValueOf(v.Clone()))
+		return nil
+	}
+	if v.IsNull() {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	mismatch := func() error {
+		if path == "" {
+			return fmt.Errorf("Cannot unmarshal %v into Go value of type %v", v.Type, rv.Type())
+		}
+		return fmt.Errorf("Cannot unmarshal %v into field %s of type %v", v.Type, path, rv.Type())
+	}
+	if rv.Type() == timeType {
+		if v.Type != TimestampType {
+			return mismatch()
+		}
+		rv.Set(reflect.ValueOf(v.Time))
+		return nil
+	}
+	if rv.Type() == bigType {
+		if v.Type != IntType {
+			return mismatch()
+		}
+		rv.Set(reflect.ValueOf(*new(big.Int).Set(v.bigInt())))
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return unmarshalValue(v, rv.Elem(), path)
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return mismatch()
+		}
+		if g := v.ToGo(); g != nil {
+			rv.Set(reflect.ValueOf(g))
+		}
+		return nil
+	case reflect.Bool:
+		if v.Type != BoolType {
+			return mismatch()
+		}
+		rv.SetBool(v.Int != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type != IntType {
+			return mismatch()
+		}
+		if v.BigInt != nil || rv.OverflowInt(v.Int) {
+			return fmt.Errorf("Cannot unmarshal %v into %s: overflows %v", v.bigInt(), describePath(path), rv.Type())
+		}
+		rv.SetInt(v.Int)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Type != IntType {
+			return mismatch()
+		}
+		n := v.bigInt()
+		if !n.IsUint64() || rv.OverflowUint(n.Uint64()) {
+			return fmt.Errorf("Cannot unmarshal %v into %s: overflows %v", n, describePath(path), rv.Type())
+		}
+		rv.SetUint(n.Uint64())
+	case reflect.Float32, reflect.Float64:
+		switch v.Type {
+		case FloatType:
+			rv.SetFloat(v.Float)
+		case IntType:
+			f, _ := new(big.Float).SetInt(v.bigInt()).Float64()
+			rv.SetFloat(f)
+		case DecimalType:
+			rv.SetFloat(v.Decimal.Float64())
+		default:
+			return mismatch()
+		}
+	case reflect.String:
+		if v.Type != StringType && v.Type != SymbolType {
+			return mismatch()
+		}
+		rv.SetString(v.Text)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 && (v.Type == BlobType || v.Type == ClobType) {
+			rv.SetBytes(append([]byte(nil), v.Bytes...))
+			return nil
+		}
+		if v.Type != ListType && v.Type != SexpType {
+			return mismatch()
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(v.Sequence), len(v.Sequence))
+		for i, item := range v.Sequence {
+			if err := unmarshalValue(item, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+	case reflect.Array:
+		if v.Type != ListType && v.Type != SexpType {
+			return mismatch()
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if i >= len(v.Sequence) {
+				rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
+				continue
+			}
+			if err := unmarshalValue(v.Sequence[i], rv.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type != StructType || rv.Type().Key().Kind() != reflect.String {
+			return mismatch()
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		for _, field := range v.Struct {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := unmarshalValue(field.Value, elem, joinPath(path, field.Name)); err != nil {
+				return err
+			}
+			rv.SetMapIndex(reflect.ValueOf(field.Name).Convert(rv.Type().Key()), elem)
+		}
+	case reflect.Struct:
+		if v.Type != StructType {
+			return mismatch()
+		}
+		for _, field := range v.Struct {
+			fv, ok := findField(rv, field.Name)
+			if !ok {
+				continue
+			}
+			if err := unmarshalValue(field.Value, fv, joinPath(path, field.Name)); err != nil {
+				return err
+			}
+		}
+	default:
+		return mismatch()
+	}
+	return nil
+}
+
+// findField returns the field of a Go struct that an Ion field name maps to: the field tagged with
+// that name, or else the first whose name matches ignoring case. Untagged embedded structs are
+// searched as if their fields belonged to the outer struct.
+func findField(rv reflect.Value, name string) (reflect.Value, bool) {
+	var fold reflect.Value
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fieldName, _, skip := fieldTag(sf)
+		if skip {
+			continue
+		}
+		fv := rv.Field(i)
+		if sf.Anonymous && sf.Tag.Get("ion") == "" {
+			if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+				if fv.IsNil() {
+					if !fv.CanSet() {
+						continue
+					}
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+				if found, ok := findField(fv, name); ok {
+					return found, true
+				}
+				continue
+			}
+		}
+		if !fv.CanSet() {
+			continue
+		}
+		if fieldName == name {
+			return fv, true
+		}
+		if !fold.IsValid() && strings.EqualFold(fieldName, name) {
+			fold = fv
+		}
+	}
+	return fold, fold.IsValid()
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "Go value"
+	}
+	return "field " + path
+}
+
+// ToGo converts the value into generic Go types, for code that works with untyped data, as
+// encoding/json does when decoding into an interface{}. Bools become bool, ints become int64 (or
+// *big.Int if too large), floats and decimals become float64, strings and symbols become string,
+// timestamps become time.Time, blobs and clobs become []byte, lists and sexps become
+// []interface{}, and structs become map[string]interface{}. Nulls of every type become nil.
+// Annotations are dropped, and if a struct has more than one field with the same name, the last
+// one wins.
+func (v Value) ToGo() interface{} {
+	if v.IsNull() {
+		return nil
+	}
+	switch v.Type {
+	case BoolType:
+		return v.Int != 0
+	case IntType:
+		if v.BigInt != nil {
+			return new(big.Int).Set(v.BigInt)
+		}
+		return v.Int
+	case FloatType:
+		return v.Float
+	case DecimalType:
+		return v.Decimal.Float64()
+	case StringType, SymbolType:
+		return v.Text
+	case TimestampType:
+		return v.Time
+	case BlobType, ClobType:
+		return append([]byte(nil), v.Bytes...)
+	case ListType, SexpType:
+		items := make([]interface{}, len(v.Sequence))
+		for i, item := range v.Sequence {
+			items[i] = item.ToGo()
+		}
+		return items
+	case StructType:
+		m := make(map[string]interface{}, len(v.Struct))
+		for _, field := range v.Struct {
+			m[field.Name] = field.Value.ToGo()
+		}
+		return m
+	}
+	return nil
+}
+
+// FromGo converts generic Go data, of the types ToGo returns, into a value. It also accepts the
+// other Go integer and float types, and nil. Maps become structs with their keys sorted, so the
+// result is deterministic. Any other type is an error; Marshal converts Go structs and typed
+// slices and maps.
+func FromGo(v interface{}) (*Value, error) {
+	val, err := fromGo(v)
+	if err != nil {
+		return nil, err
+	}
+	return &val, nil
+}
+
+func fromGo(v interface{}) (Value, error) {
+	switch t := v.(type) {
+	case nil:
+		return Null(), nil
+	case bool:
+		return Bool(t), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, *big.Int:
+		return marshalValue(reflect.ValueOf(t))
+	case string:
+		return Str(t), nil
+	case time.Time:
+		return marshalValue(reflect.ValueOf(t))
+	case []byte:
+		return Value{Type: BlobType, Bytes: append([]byte(nil), t...)}, nil
+	case []interface{}:
+		items := make([]Value, len(t))
+		for i, item := range t {
+			val, err := fromGo(item)
+			if err != nil {
+				return Value{}, err
+			}
+			items[i] = val
+		}
+		return Value{Type: ListType, Sequence: items}, nil
+	case map[string]interface{}:
+		names := make([]string, 0, len(t))
+		for name := range t {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fields := make([]Field, len(names))
+		for i, name := range names {
+			val, err := fromGo(t[name])
+			if err != nil {
+				return Value{}, fmt.Errorf("Field %s: %v", name, err)
+			}
+			fields[i] = Field{Name: name, Value: val}
+		}
+		return Value{Type: StructType, Struct: fields}, nil
+	}
+	return Value{}, fmt.Errorf("Cannot convert a %T to an Ion value", v)
+}
@@ -0,0 +1,200 @@
+package ion
+
+import (
+	"reflect"
+	"testing"
+)
+
+type marshalAddress struct {
+	City string `ion:"city"`
+	Zip  string `ion:"zip,omitempty"`
+}
+
+type marshalBase struct {
+	ID int64 `ion:"id"`
+}
+
+type marshalPerson struct {
+	marshalBase
+	Name    string            `ion:"name"`
+	Age     int               `ion:"age,omitempty"`
+	Tags    []string          `ion:"tags"`
+	Home    *marshalAddress   `ion:"home"`
+	Work    *marshalAddress   `ion:"work"`
+	Extra   map[string]int    `ion:"extra"`
+	Secret  string            `ion:"-"`
+	Score   float64           //no tag, so named by the Go field
+	Data    []byte            `ion:"data"`
+	Raw     Value             `ion:"raw"`
+	private string            //unexported, so skipped
+	Labels  map[string]string `ion:"labels,omitempty"`
+}
+
+func TestMarshal(t *testing.T) {
+	p := marshalPerson{
+		marshalBase: marshalBase{ID: 7},
+		Name:        "ann",
+		Tags:        []string{"a", "b"},
+		Home:        &marshalAddress{City: "Paris"},
+		Extra:       map[string]int{"z": 26, "a": 1},
+		Secret:      "hidden",
+		Score:       1.5,
+		Data:        []byte("hi"),
+		Raw:         Sym("r"),
+		private:     "x",
+	}
+	want := NewStruct().
+		Field("id", Int(7)).
+		Field("name", Str("ann")).
+		Field("tags", NewList(Str("a"), Str("b"))).
+		Field("home", NewStruct().Field("city", Str("Paris"))).
+		Field("work", Null()).
+		Field("extra", NewStruct().Field("a", Int(1)).Field("z", Int(26))).
+		Field("Score", Value{Type: FloatType, Float: 1.5}).
+		Field("data", Value{Type: BlobType, Bytes: []byte("hi")}).
+		Field("raw", Sym("r"))
+	got, err := Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("Marshal:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	tests := []struct {
+		v   interface{}
+		err string
+	}{
+		{make(chan int), "Cannot marshal value of type chan int"},
+		{func() {}, "Cannot marshal value of type func()"},
+		{map[int]string{1: "a"}, "Cannot marshal map with int keys: keys must be strings"},
+		{[]interface{}{1, complex(1, 2)}, "Cannot marshal value of type complex128"},
+	}
+	for _, test := range tests {
+		_, err := Marshal(test.v)
+		if got := errorString(err); got != test.err {
+			t.Errorf("Marshal(%T): got error %q, want %q", test.v, got, test.err)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	const src = `{id: 7, NAME: "ann", age: null, tags: [a, "b"], home: {city: "Paris", zip: "75001"},
+		extra: {a: 1, z: 26}, score: 2, data: {{aGk=}}, raw: x::1, unknown: 5}`
+	var got marshalPerson
+	got.Age = 30
+	if err := mustParse(t, src).Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := marshalPerson{
+		marshalBase: marshalBase{ID: 7},
+		Name:        "ann",
+		Tags:        []string{"a", "b"},
+		Home:        &marshalAddress{City: "Paris", Zip: "75001"},
+		Extra:       map[string]int{"a": 1, "z": 26},
+		Score:       2,
+		Data:        []byte("hi"),
+		Raw:         Int(1).Annotate("x"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"{id: {}}", "Cannot unmarshal struct into field id of type int64"},
+		{`{home: {city: 5}}`, "Cannot unmarshal int into field home.city of type string"},
+		{`{tags: [a, 1]}`, "Cannot unmarshal int into field tags[1] of type string"},
+		{`{name: [1]}`, "Cannot unmarshal list into field name of type string"},
+		{`{extra: {a: 99999999999999999999}}`, "Cannot unmarshal 99999999999999999999 into field extra.a: overflows int"},
+		{`[1]`, "Cannot unmarshal list into Go value of type ion.marshalPerson"},
+	}
+	for _, test := range tests {
+		var p marshalPerson
+		err := mustParse(t, test.src).Unmarshal(&p)
+		if got := errorString(err); got != test.err {
+			t.Errorf("Unmarshal(%s): got error %q, want %q", test.src, got, test.err)
+		}
+	}
+	var p marshalPerson
+	if got, want := errorString(Int(1).Unmarshal(p)), "Cannot unmarshal into ion.marshalPerson: target must be a non-nil pointer"; got != want {
+		t.Errorf("Unmarshal into a non-pointer: got error %q, want %q", got, want)
+	}
+}
+
+func TestToGo(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"null", nil},
+		{"null.int", nil},
+		{"true", true},
+		{"42", int64(42)},
+		{"1.5e0", 1.5},
+		{"2.5", 2.5},
+		{`"hi"`, "hi"},
+		{"sym", "sym"},
+		{"a::1", int64(1)},
+		{`{{aGk=}}`, []byte("hi")},
+		{`[1, "a", null]`, []interface{}{int64(1), "a", nil}},
+		{"(+ 1)", []interface{}{"+", int64(1)}},
+		{"{a: 1, b: {c: [true]}}", map[string]interface{}{"a": int64(1), "b": map[string]interface{}{"c": []interface{}{true}}}},
+		{"{a: 1, a: 2}", map[string]interface{}{"a": int64(2)}},
+	}
+	for _, test := range tests {
+		if got := mustParse(t, test.src).ToGo(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s.ToGo(): got %#v, want %#v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestFromGo(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+		err  string
+	}{
+		{nil, "null", ""},
+		{true, "true", ""},
+		{7, "7", ""},
+		{uint8(7), "7", ""},
+		{"hi", `"hi"`, ""},
+		{[]interface{}{int64(1), "a", nil}, `[1, "a", null]`, ""},
+		{map[string]interface{}{"b": 2, "a": []interface{}{true}}, "{a: [true], b: 2}", ""},
+		{map[string]interface{}{"a": struct{}{}}, "", "Field a: Cannot convert a struct {} to an Ion value"},
+		{[]int{1}, "", "Cannot convert a []int to an Ion value"},
+	}
+	for _, test := range tests {
+		v, err := FromGo(test.in)
+		if got := errorString(err); got != test.err {
+			t.Errorf("FromGo(%#v): got error %q, want %q", test.in, got, test.err)
+			continue
+		}
+		if err == nil && v.String() != test.want {
+			t.Errorf("FromGo(%#v): got %s, want %s", test.in, v, test.want)
+		}
+	}
+}
+
+func TestToGoRoundTrip(t *testing.T) {
+	m := map[string]interface{}{
+		"name":  "x",
+		"count": int64(3),
+		"tags":  []interface{}{"a", "b"},
+		"inner": map[string]interface{}{"ok": true, "none": nil},
+	}
+	v, err := FromGo(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := v.ToGo(); !reflect.DeepEqual(got, m) {
+		t.Errorf("FromGo(m).ToGo(): got %#v, want %#v", got, m)
+	}
+}
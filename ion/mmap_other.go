@@ -0,0 +1,18 @@
+//go:build !unix
+
+package ion
+
+import (
+	"os"
+)
+
+// ParseFileMmap opens a file for reading its top-level values one at a time. Memory mapping is
+// only available on Unix systems, so here the file is read into memory instead, and the returned
+// function does nothing.
+func ParseFileMmap(path string) (*Reader, func() error, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newFileReader(path, data), func() error { return nil }, nil
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package ion
+
+import (
+	"os"
+	"syscall"
+)
+
+// ParseFileMmap opens a file for reading its top-level values one at a time, with the file mapped
+// into memory rather than read into the heap, so a file of many gigabytes is paged in by the OS
+// as it is parsed. The returned function unmaps the file, after which the Reader must not be used.
+//
+// Memory mapping uses syscall.Mmap, so it is only available on Unix systems. Elsewhere the file is
+// read into memory instead.
+func ParseFileMmap(path string) (*Reader, func() error, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fi.Close()
+	info, err := fi.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() == 0 {
+		//an empty file cannot be mapped, and has nothing to read anyway
+		return newFileReader(path, nil), func() error { return nil }, nil
+	}
+	data, err := syscall.Mmap(int(fi.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newFileReader(path, data), func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,84 @@
+//go:build unix
+
+package ion
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFileMmap(t *testing.T) {
+	const records = 100000
+	path := filepath.Join(t.TempDir(), "large.ion")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	for i := 0; i < records; i++ {
+		fmt.Fprintf(w, "{id: %d, name: \"record %d\", tags: [a, b, c]}\n", i, i)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, unmap, err := ParseFileMmap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unmap()
+	n := 0
+	for {
+		v, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("record %d: %v", n, err)
+		}
+		if id, _ := v.GetInt("id"); id != int64(n) {
+			t.Fatalf("record %d: got id %d", n, id)
+		}
+		n++
+	}
+	if n != records {
+		t.Errorf("got %d records, want %d", n, records)
+	}
+}
+
+func TestParseFileMmapEdgeCases(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.ion")
+	bad := filepath.Join(dir, "bad.ion")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte("1\n[2,\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, unmap, err := ParseFileMmap(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("reading an empty file: got %v, want io.EOF", err)
+	}
+	unmap()
+	r, unmap, err = ParseFileMmap(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unmap()
+	r.Next()
+	if _, err := r.Next(); errorString(err) != bad+":3:1: expected ']' to close list opened at 2:1, found EOF" {
+		t.Errorf("reading a bad file: got error %v", err)
+	}
+	if _, _, err := ParseFileMmap(filepath.Join(dir, "missing.ion")); !os.IsNotExist(err) {
+		t.Errorf("mapping a missing file: got error %v", err)
+	}
+}
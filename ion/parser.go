@@ -2,24 +2,121 @@ package ion
 
 import (
 	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type Parser struct {
-	scanner *Scanner
-	err     error
-	source  string
-	buf     struct {
-		tok Token  // last read token
-		lit string // last read literal
-		n   int    // buffer size (max=1)
+	// Strict requires exactly one comma between the elements of lists and the fields of structs,
+	// rather than treating commas as optional.
+	Strict bool
+
+	// NumericAnnotations parses numbers annotated with decimal:: or float:: as that type, whatever
+	// form the number is written in, so float::1.5 is a float rather than a decimal.
+	NumericAnnotations bool
+
+	// NumericKeys accepts unquoted numbers as struct field names, as in {1: "a"}, using their text
+	// as the name. Ion itself only allows symbols and strings.
+	NumericKeys bool
+
+	// AllowTrailingPoint accepts numbers with no digits after the decimal point, such as 5. or
+	// 5.e3, reading them as if a zero followed the point (5.0 and 5.0e3). Ion does not allow them.
+	AllowTrailingPoint bool
+
+	// SemicolonSeparated treats a ';' between top-level values as the end of one document and the
+	// start of the next, as in files holding several configs. Reader.Document reports which
+	// document a value came from. Otherwise ';' is only allowed as an operator in an s-expression.
+	SemicolonSeparated bool
+
+	// FieldNameAnnotations accepts annotations on struct field names, as in {a::name: 1}, which
+	// some extended dialects of Ion use. They are kept in the field's NameAnnotations. Otherwise an
+	// annotated field name is a syntax error.
+	FieldNameAnnotations bool
+
+	// CollectDiagnostics records unusual but valid whitespace, such as vertical tabs and
+	// indentation that mixes tabs and spaces, for linting. They are returned by Diagnostics.
+	CollectDiagnostics bool
+
+	// StrictLeadingZeros rejects numbers with a redundant leading zero, such as 007 or 01.5, which
+	// Ion does not allow. Otherwise they are read as if the zeros were not there.
+	StrictLeadingZeros bool
+
+	// MaxAnnotations limits how many annotations a single value may have, to guard against
+	// pathological input such as a::a::a::...::1. If zero, the limit is 100; if negative, there is
+	// no limit.
+	MaxAnnotations int
+
+	// MaxDepth limits how deeply containers may be nested, to guard against hostile input such as
+	// [[[[...]]]], whose values would be costly to print, compare, or copy. If zero, the limit is
+	// 200; if negative, there is no limit.
+	MaxDepth int
+
+	// KeepComments keeps the // and /* */ comments before each value in the value's Comments, as
+	// written, so that tools like formatters can write them out again. Comments at the end of a
+	// container or of the input, after the last value, are dropped.
+	KeepComments bool
+
+	scanner  *Scanner
+	ctx      context.Context //if set, parsing stops with an error once it is done
+	checks   int             //the number of calls to checkContext, which only looks at ctx now and then
+	document int             //the number of top-level ';' separators read, if SemicolonSeparated
+	err      error
+	collect  bool //set by ParseCollect to record errors in errs and carry on
+	errs     []error
+	source   string
+	buf      struct {
+		ring [lookahead]scanned // the most recently read tokens
+		head int                // index in ring of the last token returned by scan
+		n    int                // number of unscanned tokens, after head, to return again
 	}
 }
 
+// contextInterval is how many tokens the parser reads between checks of its context, which are
+// not free.
+const contextInterval = 64
+
+// defaultMaxAnnotations is the limit on annotations per value when the parser does not set one.
+const defaultMaxAnnotations = 100
+
+// defaultMaxDepth is the limit on the nesting of containers when the parser does not set one.
+const defaultMaxDepth = 200
+
+// lookahead is how many tokens the parser can give back with unscan, to look ahead of where it is.
+const lookahead = 4
+
+// scanned is a token read by the parser, with the position where it started.
+type scanned struct {
+	tok       Token
+	lit       string
+	line, col int
+	comments  []string //the comments just before the token, if KeepComments is set
+}
+
+// SyntaxError is an error in the input, with the position where it was found.
+type SyntaxError struct {
+	Source string // the name of the input, if the parser was given one
+	Line   int    // 1-based
+	Col    int    // 1-based
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.Source, e.Line, e.Col, e.Msg)
+}
+
 func ParseFile(path string) (*Value, error) {
 	fi, err := os.Open(path)
 	if err != nil {
@@ -34,166 +131,923 @@ func Parse(reader io.Reader) (*Value, error) {
 	return parseFrom("", reader)
 }
 
+// ParseStrict parses like Parse, but requires commas between list elements and struct fields.
+func ParseStrict(reader io.Reader) (*Value, error) {
+	p := NewParser("", reader)
+	p.Strict = true
+	return p.Parse()
+}
+
+// ParseCollect parses like Parse, but rather than stopping at the first error, it records the
+// error and skips to the next ',' or closing delimiter, so a single run reports every problem in
+// the input. It returns the errors, each a *SyntaxError with the position of the problem, along
+// with as much of the value as could be parsed. Open containers are closed at the end of the
+// input, and a closing delimiter that belongs to an enclosing container closes those inside it.
+func ParseCollect(reader io.Reader) (*Value, []error) {
+	p := NewParser("", reader)
+	p.collect = true
+	val, err := p.Parse()
+	if err != nil {
+		p.errs = append(p.errs, err)
+	}
+	return val, p.errs
+}
+
+// ParseWithTimeout parses like Parse, but returns an error if parsing takes longer than d. The
+// deadline is checked as tokens are read, so a read that never returns is not interrupted.
+func ParseWithTimeout(reader io.Reader, d time.Duration) (*Value, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return ParseContext(ctx, reader)
+}
+
+// ParseContext parses like Parse, but stops with an error wrapping the context's error once ctx is
+// canceled or its deadline passes. The context is checked every so often as tokens are read, so a
+// read that never returns is not interrupted.
+func ParseContext(ctx context.Context, reader io.Reader) (*Value, error) {
+	p := NewParser("", reader)
+	p.ctx = ctx
+	return p.Parse()
+}
+
 func parseFrom(source string, reader io.Reader) (*Value, error) {
-	p := &Parser{scanner: NewScanner(reader), source: source}
+	return NewParser(source, reader).Parse()
+}
+
+// NewParser returns a parser reading from reader. The source names the input in error messages.
+func NewParser(source string, reader io.Reader) *Parser {
+	return &Parser{scanner: NewScanner(reader), source: source}
+}
+
+// Reset makes the parser read from reader as if it were new, keeping its options and reusing its
+// scanner, so that one parser can parse many inputs without allocating for each.
+func (p *Parser) Reset(source string, reader io.Reader) {
+	p.scanner.Reset(reader)
+	p.source = source
+	p.document = 0
+	p.err = nil
+	p.errs = nil
+	p.buf.head, p.buf.n = 0, 0
+}
+
+// Parse parses the next value from the input. It returns a nil value at the end of the input.
+func (p *Parser) Parse() (*Value, error) {
 	return p.parse()
 }
 
+// Next returns the next top-level value from the input, or io.EOF once the input is exhausted, so
+// a stream of values can be processed one at a time.
+func (p *Parser) Next() (*Value, error) {
+	for {
+		if err := p.checkContext(); err != nil {
+			return nil, err
+		}
+		tok, lit := p.scan()
+		if tok == EOF {
+			return nil, io.EOF
+		}
+		val, err := p.parseToken(tok, lit)
+		if err != nil {
+			return nil, p.syntaxError(err)
+		}
+		if val != nil {
+			return val, nil
+		}
+	}
+}
+
+// Skip reads past the next top-level value, with its annotations, without building it, returning
+// io.EOF when the input is exhausted. A container is skipped by matching its delimiters, so it
+// costs little however large it is, but its contents are not checked beyond what the scanner
+// itself rejects.
+func (p *Parser) Skip() error {
+	for {
+		if err := p.checkContext(); err != nil {
+			return err
+		}
+		tok, lit := p.scan()
+		switch {
+		case tok == EOF:
+			return io.EOF
+		case tok == COMMA || tok == COLON || p.versionMarker(tok, lit):
+			continue
+		case tok == ILLEGAL && lit == ";" && p.SemicolonSeparated:
+			p.document++
+			continue
+		}
+		if err := p.skip(tok, lit); err != nil {
+			return p.syntaxError(err)
+		}
+		return nil
+	}
+}
+
+// skip reads past the value beginning with tok.
+func (p *Parser) skip(tok Token, lit string) error {
+	_, tok, lit, err := p.parseAnnotations(tok, lit)
+	if err != nil {
+		return err
+	}
+	switch {
+	case tok == LONG_STRING:
+		//adjacent long strings make up a single value
+		for tok == LONG_STRING {
+			tok, _ = p.scan()
+		}
+		p.unscan()
+		return nil
+	case tok == ILLEGAL:
+		return illegalError(lit)
+	case tok == EOF || isCloser(tok) || tok == DOUBLE_COLON:
+		return fmt.Errorf("Unexpected %q", lit)
+	case !isOpener(tok):
+		return nil
+	}
+	stack := []*container{p.openContainer(tok)}
+	defer func() { p.scanner.sexp = false }()
+	for len(stack) > 0 {
+		if err := p.checkContext(); err != nil {
+			return err
+		}
+		c := stack[len(stack)-1]
+		p.scanner.sexp = c.typ == SexpType
+		tok, lit := p.scan()
+		switch {
+		case tok == c.end:
+			stack = stack[:len(stack)-1]
+		case tok == EOF || isCloser(tok):
+			return p.closerError(c.end, c.line, c.col, tok, lit)
+		case tok == ILLEGAL:
+			return illegalError(lit)
+		case isOpener(tok):
+			stack = append(stack, p.openContainer(tok))
+		}
+	}
+	return nil
+}
+
+// ParseAll parses every top-level value in the input.
+func ParseAll(reader io.Reader) ([]*Value, error) {
+	p := NewParser("", reader)
+	var values []*Value
+	for {
+		val, err := p.Next()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, val)
+	}
+}
+
+// Diagnostics returns the notes recorded about the input so far, if CollectDiagnostics is set.
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.scanner.diagnostics
+}
+
+// scan returns the next token, skipping whitespace. Tokens given back by unscan are returned again
+// first, in their original order.
 func (p *Parser) scan() (tok Token, lit string) {
-	if p.buf.n != 0 {
-		p.buf.n = 0
-		return p.buf.tok, p.buf.lit
+	b := &p.buf
+	b.head = (b.head + 1) % lookahead
+	if b.n > 0 {
+		b.n--
+	} else {
+		p.scanner.diagnose = p.CollectDiagnostics
+		p.scanner.comments = p.KeepComments
+		var comments []string
+		tok, lit = p.scanner.Scan()
+		for tok == WHITESPACE || tok == COMMENT {
+			if tok == COMMENT {
+				comments = append(comments, lit)
+			}
+			tok, lit = p.scanner.Scan()
+		}
+		line, col := p.scanner.Position()
+		b.ring[b.head] = scanned{tok: tok, lit: lit, line: line, col: col, comments: comments}
 	}
-	tok, lit = p.scanner.Scan()
-	p.buf.tok, p.buf.lit = tok, lit
+	t := b.ring[b.head]
+	return t.tok, t.lit
+}
 
-	return
+// unscan gives back the last token returned by scan. It can be called repeatedly to back up over
+// as many as lookahead tokens.
+func (p *Parser) unscan() {
+	b := &p.buf
+	if b.n == lookahead {
+		panic("ion: parser lookahead exceeded")
+	}
+	b.n++
+	b.head = (b.head + lookahead - 1) % lookahead
 }
 
-func (p *Parser) unscan() { p.buf.n = 1 }
+// peek returns the next n tokens, up to lookahead, without consuming them.
+func (p *Parser) peek(n int) []Token {
+	toks := make([]Token, n)
+	for i := range toks {
+		toks[i], _ = p.scan()
+	}
+	for range toks {
+		p.unscan()
+	}
+	return toks
+}
 
-func (p *Parser) scanIgnoreWhitespace() (tok Token, lit string) {
-	tok, lit = p.scan()
-	if tok == WHITESPACE {
+// comments returns the comments just before the last read token.
+func (p *Parser) comments() []string {
+	return p.buf.ring[p.buf.head].comments
+}
+
+// position returns the line and column of the last read token.
+func (p *Parser) position() (line, col int) {
+	t := p.buf.ring[p.buf.head]
+	return t.line, t.col
+}
+
+func (p *Parser) parse() (*Value, error) {
+	if err := p.checkContext(); err != nil {
+		return nil, err
+	}
+	tok, lit := p.scan()
+	for p.versionMarker(tok, lit) {
 		tok, lit = p.scan()
 	}
-	return
+	val, err := p.parseToken(tok, lit)
+	if err != nil {
+		return nil, p.syntaxError(err)
+	}
+	return val, nil
 }
 
-func (p *Parser) parse() (*Value, error) {
-	tok, lit := p.scanIgnoreWhitespace()
-	return p.parseToken(tok, lit)
+// versionMarker reports whether a top-level token is an Ion version marker, such as $ion_1_0,
+// which says what version of Ion follows rather than being a value. The same symbol quoted,
+// annotated, or inside a container is an ordinary symbol.
+func (p *Parser) versionMarker(tok Token, lit string) bool {
+	return tok == SYMBOL && isVersionMarker(lit) && p.peek(1)[0] != DOUBLE_COLON
+}
+
+// isVersionMarker reports whether a symbol has the form of a version marker, $ion_ followed by
+// the major and minor version numbers.
+func isVersionMarker(lit string) bool {
+	if !strings.HasPrefix(lit, "$ion_") {
+		return false
+	}
+	major, minor, ok := strings.Cut(lit[len("$ion_"):], "_")
+	return ok && isDigits(major) && isDigits(minor)
+}
+
+// syntaxError returns err, found while parsing the last read token, as a SyntaxError at the
+// token's position. Errors from the parser's context are returned as they are.
+func (p *Parser) syntaxError(err error) error {
+	if _, ok := err.(*SyntaxError); ok || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	line, col := p.position()
+	return &SyntaxError{Source: p.source, Line: line, Col: col, Msg: err.Error()}
+}
+
+// checkContext returns an error once the parser's context is done.
+func (p *Parser) checkContext() error {
+	if p.ctx == nil {
+		return nil
+	}
+	p.checks++
+	if p.checks%contextInterval != 1 {
+		return nil
+	}
+	switch err := p.ctx.Err(); err {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return fmt.Errorf("Parse timed out: %w", err)
+	default:
+		return fmt.Errorf("Parse canceled: %w", err)
+	}
 }
 
 func (p *Parser) parseToken(tok Token, lit string) (*Value, error) {
-	if tok != EOF {
-		if tok == ILLEGAL {
-			p.err = fmt.Errorf("token not handled: %s - %q", tok, lit)
-			return nil, p.err
+	if tok == EOF {
+		return nil, nil
+	}
+	if tok == COMMA || tok == COLON || p.versionMarker(tok, lit) {
+		return nil, nil //we basically ignore commas
+	}
+	if tok == ILLEGAL && lit == ";" && p.SemicolonSeparated {
+		p.document++
+		return nil, nil
+	}
+	comments := p.comments()
+	annotations, tok, lit, err := p.parseAnnotations(tok, lit)
+	if err != nil {
+		return nil, err
+	}
+	var val *Value
+	if isOpener(tok) {
+		val, err = p.parseContainer(tok)
+		if err != nil {
+			return nil, err
 		}
-		switch tok {
-		case SYMBOL:
-			nextTok, _ := p.scanIgnoreWhitespace()
-			if nextTok == DOUBLE_COLON {
-				//fix me to not be a hack that assumes only s single annotation
-				val, err := p.parse()
-				if err == nil && val != nil {
-					val.Annotations = []string{lit}
-				}
-				return val, err
-			} else {
-				p.unscan()
-			}
-			if lit == "true" {
-				return &Value{Type: BoolType, Int: 1}, nil
-			} else if lit == "false" {
-				return &Value{Type: BoolType, Int: 0}, nil
-			} else if lit == "null" {
-				return &Value{Type: NullType}, nil
-			}
-			return &Value{Type: SymbolType, Text: lit}, nil
-		case OPEN_PAREN:
-			return p.parseSequence(CLOSE_PAREN)
-		case OPEN_BRACKET:
-			return p.parseSequence(CLOSE_BRACKET)
-		case OPEN_BRACE:
-			return p.parseStruct()
-		case CLOSE_BRACE, CLOSE_BRACKET, CLOSE_PAREN, DOUBLE_COLON:
-			return nil, fmt.Errorf("Unexpected %q", string(tok))
-		case COMMA, COLON:
-			return nil, nil //we basically ignore commas
-		case NUMBER:
-			if strings.Index(lit, ".") >= 0 {
-				//to do: handle arbitrary precision decimal
-				if !strings.HasPrefix(lit, "0x") && !strings.HasPrefix(lit, "0b") {
-					n, err := strconv.ParseFloat(lit, 64)
-					if err == nil {
-						return &Value{Type: FloatType, Float: n}, nil
-					}
-				}
-				return nil, fmt.Errorf("Cannot parse real number: %q", lit)
-			} else {
-				base := 10
-				if strings.HasPrefix(lit, "0x") {
-					base = 16
-					lit = lit[2:]
-				} else if strings.HasPrefix(lit, "0b") {
-					base = 2
-					lit = lit[2:]
+		val.Annotations = annotations
+	} else if val, err = p.parseAnnotatedScalar(annotations, tok, lit); err != nil {
+		return nil, err
+	}
+	val.Comments = comments
+	return val, nil
+}
+
+// parseAnnotatedScalar parses a scalar value and attaches its annotations.
+func (p *Parser) parseAnnotatedScalar(annotations []string, tok Token, lit string) (*Value, error) {
+	var val *Value
+	var err error
+	if tok == NUMBER {
+		if lit, err = stripUnderscores(lit); err != nil {
+			return nil, err
+		}
+		if lit, err = p.checkTrailingPoint(lit); err != nil {
+			return nil, err
+		}
+		if p.StrictLeadingZeros && hasLeadingZero(lit) {
+			return nil, fmt.Errorf("Invalid number %q: leading zeros are not allowed", lit)
+		}
+	}
+	if tok == NUMBER && p.NumericAnnotations {
+		val, err = parseAnnotatedNumber(annotations, lit)
+	} else {
+		val, err = p.parseScalar(tok, lit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	val.Annotations = annotations
+	return val, nil
+}
+
+// stripUnderscores removes the underscores that separate the digits of a number literal, as in
+// 1_000_000, rejecting any that are not between two digits.
+func stripUnderscores(lit string) (string, error) {
+	if !strings.Contains(lit, "_") {
+		return lit, nil
+	}
+	isDigitOf := isDigit
+	if base, _ := radix(lit); base == 16 {
+		isDigitOf = func(ch rune) bool { return hexValue(ch) >= 0 }
+	}
+	for i := 0; i < len(lit); i++ {
+		if lit[i] == '_' && (i == 0 || i == len(lit)-1 || !isDigitOf(rune(lit[i-1])) || !isDigitOf(rune(lit[i+1]))) {
+			return "", fmt.Errorf("Invalid number %q: an underscore must be between two digits", lit)
+		}
+	}
+	return strings.ReplaceAll(lit, "_", ""), nil
+}
+
+// checkTrailingPoint rejects a decimal number literal with no digits after its point, unless the
+// parser allows them, in which case it returns the literal with a zero after the point.
+func (p *Parser) checkTrailingPoint(lit string) (string, error) {
+	i := strings.Index(lit, ".")
+	if base, _ := radix(lit); base != 10 || i < 0 || (i+1 < len(lit) && isDigit(rune(lit[i+1]))) {
+		return lit, nil
+	}
+	if !p.AllowTrailingPoint {
+		return "", fmt.Errorf("Invalid number %q: a decimal point must be followed by a digit", lit)
+	}
+	return lit[:i+1] + "0" + lit[i+1:], nil
+}
+
+// hasLeadingZero reports whether a base 10 number literal has a zero before other digits in its
+// integer part.
+func hasLeadingZero(lit string) bool {
+	if base, _ := radix(lit); base != 10 {
+		return false
+	}
+	digits := strings.TrimLeft(lit, "+-")
+	return len(digits) > 1 && digits[0] == '0' && isDigit(rune(digits[1]))
+}
+
+// parseAnnotatedNumber parses a number literal as a decimal or a float if its annotations include
+// "decimal" or "float", whichever comes first, and otherwise as usual.
+func parseAnnotatedNumber(annotations []string, lit string) (*Value, error) {
+	if base, _ := radix(lit); base == 10 {
+		for _, anno := range annotations {
+			switch anno {
+			case "decimal":
+				d, err := ParseDecimal(strings.NewReplacer("e", "d", "E", "d").Replace(lit))
+				if err != nil {
+					return nil, err
 				}
-				i, err := strconv.ParseInt(lit, base, 64)
+				return &Value{Type: DecimalType, Decimal: d}, nil
+			case "float":
+				f, err := strconv.ParseFloat(strings.NewReplacer("d", "e", "D", "e").Replace(lit), 64)
 				if err != nil {
-					return nil, fmt.Errorf("Cannot parse base %d integer: %q", base, lit)
+					return nil, fmt.Errorf("Cannot parse real number: %q", lit)
 				}
-				return &Value{Type: IntType, Int: i}, nil
+				return &Value{Type: FloatType, Float: f}, nil
 			}
-		case STRING:
-			return &Value{Type: StringType, Text: lit}, nil
-		default:
-			p.err = fmt.Errorf("token not handled: %s - %q", tok, lit)
-			return nil, p.err
 		}
 	}
-	return nil, nil
+	return parseNumber(lit)
 }
 
-func (p *Parser) parseSequence(end Token) (*Value, error) {
-	seq := make([]Value, 0)
-	tok, lit := p.scanIgnoreWhitespace()
-	for tok != EOF {
-		if tok == CLOSE_BRACKET || tok == CLOSE_PAREN {
-			if end != tok {
-				return nil, fmt.Errorf("Bad sequence, expecting %v, encounted %s", end, tok)
-			}
-			if end == CLOSE_PAREN {
-				return &Value{Type: SexpType, Sequence: seq}, nil
+// parseAnnotations reads any "symbol::" annotations preceding a value, returning them along with
+// the first token of the value itself.
+func (p *Parser) parseAnnotations(tok Token, lit string) ([]string, Token, string, error) {
+	var annotations []string
+	for tok == SYMBOL || tok == QUOTED_SYMBOL || tok == SYMBOL_ID {
+		nextTok, _ := p.scan()
+		if nextTok != DOUBLE_COLON {
+			p.unscan()
+			break
+		}
+		annotations = append(annotations, lit)
+		if limit := p.maxAnnotations(); limit >= 0 && len(annotations) > limit {
+			return nil, tok, lit, fmt.Errorf("Too many annotations on one value: the limit is %d", limit)
+		}
+		tok, lit = p.scan()
+	}
+	if annotations != nil {
+		switch tok {
+		case EOF, COMMA, COLON, DOUBLE_COLON, CLOSE_BRACE, CLOSE_BRACKET, CLOSE_PAREN:
+			return nil, tok, lit, fmt.Errorf("Missing value after annotation %q", annotations[len(annotations)-1])
+		}
+	}
+	return annotations, tok, lit, nil
+}
+
+func (p *Parser) maxAnnotations() int {
+	if p.MaxAnnotations == 0 {
+		return defaultMaxAnnotations
+	}
+	return p.MaxAnnotations
+}
+
+func (p *Parser) maxDepth() int {
+	if p.MaxDepth == 0 {
+		return defaultMaxDepth
+	}
+	return p.MaxDepth
+}
+
+// parseScalar parses a value that is not a container.
+func (p *Parser) parseScalar(tok Token, lit string) (*Value, error) {
+	switch tok {
+	case SYMBOL:
+		if lit == "true" {
+			return &Value{Type: BoolType, Int: 1}, nil
+		} else if lit == "false" {
+			return &Value{Type: BoolType, Int: 0}, nil
+		} else if lit == "null" {
+			return &Value{Type: NullType}, nil
+		} else if strings.HasPrefix(lit, "null.") {
+			t, ok := typeNamed(lit[5:])
+			if !ok {
+				return nil, fmt.Errorf("Invalid typed null: %q", lit)
 			}
-			return &Value{Type: ListType, Sequence: seq}, nil
-		} else {
-			//to do: fix this to error on missing commas, this assumes they are optional
-			elem, err := p.parseToken(tok, lit)
-			if err != nil {
-				return nil, err
+			return &Value{Type: t, Null: t != NullType}, nil
+		} else if lit == "nan" {
+			return &Value{Type: FloatType, Float: math.NaN()}, nil
+		}
+		return &Value{Type: SymbolType, Text: lit}, nil
+	case QUOTED_SYMBOL:
+		return &Value{Type: SymbolType, Text: lit}, nil
+	case SYMBOL_ID:
+		//without a symbol table to resolve it, a symbol ID keeps its text, such as "$10"
+		return &Value{Type: SymbolType, Text: lit, SymbolID: true}, nil
+	case NUMBER:
+		return parseNumber(lit)
+	case STRING:
+		return &Value{Type: StringType, Text: lit}, nil
+	case LONG_STRING:
+		//adjacent long strings, separated only by whitespace and comments, make up a single string
+		text := lit
+		for {
+			tok, lit := p.scan()
+			if tok != LONG_STRING {
+				p.unscan()
+				break
 			}
-			if elem != nil {
-				seq = append(seq, *elem)
+			text += lit
+		}
+		return &Value{Type: StringType, Text: text}, nil
+	case BLOB:
+		b, err := base64.StdEncoding.DecodeString(lit)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid base64 in blob: %v", err)
+		}
+		return &Value{Type: BlobType, Bytes: b}, nil
+	case CLOB:
+		return &Value{Type: ClobType, Bytes: []byte(lit)}, nil
+	case TIMESTAMP:
+		return parseTimestamp(lit)
+	case CLOSE_BRACE, CLOSE_BRACKET, CLOSE_PAREN, DOUBLE_COLON, COMMA, COLON:
+		return nil, fmt.Errorf("Unexpected %q", lit)
+	case ILLEGAL:
+		return nil, illegalError(lit)
+	default:
+		p.err = fmt.Errorf("token not handled: %s - %q", tok, lit)
+		return nil, p.err
+	}
+}
+
+// illegalError describes an ILLEGAL token, whose literal is either a character that cannot start a
+// token or a description of what is wrong, such as an unterminated string.
+func illegalError(lit string) error {
+	if utf8.RuneCountInString(lit) == 1 {
+		return fmt.Errorf("Unexpected %q", lit)
+	}
+	return fmt.Errorf("Invalid token: %s", lit)
+}
+
+// parseNumber converts a NUMBER literal into a value. Hex and binary literals are always integers,
+// and are recognized before anything else so that an 'e' in a hex literal is only ever a digit.
+func parseNumber(lit string) (*Value, error) {
+	if lit == "+inf" {
+		return &Value{Type: FloatType, Float: math.Inf(1)}, nil
+	} else if lit == "-inf" {
+		return &Value{Type: FloatType, Float: math.Inf(-1)}, nil
+	}
+	if base, digits := radix(lit); base != 10 {
+		if strings.Contains(digits, ".") {
+			kind := "hexadecimal"
+			if base == 2 {
+				kind = "binary"
 			}
-			tok, lit = p.scanIgnoreWhitespace()
+			return nil, fmt.Errorf("Invalid number %q: %s literals may not contain a decimal point", lit, kind)
+		}
+		return parseInt(digits, base)
+	}
+	if strings.ContainsAny(lit, "eE") {
+		f, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot parse real number: %q", lit)
+		}
+		return &Value{Type: FloatType, Float: f}, nil
+	}
+	if strings.ContainsAny(lit, ".dD") {
+		d, err := ParseDecimal(lit)
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Type: DecimalType, Decimal: d}, nil
+	}
+	return parseInt(lit, 10)
+}
+
+// parseInt parses the signed digits of an integer in the given base, which the value keeps so that
+// it is written the same way. Ion integers have no limit on their size, so one too large for an
+// int64 is kept in a big.Int instead.
+func parseInt(digits string, base int) (*Value, error) {
+	intBase := base
+	if base == 10 {
+		intBase = 0
+	}
+	i, err := strconv.ParseInt(digits, base, 64)
+	if err == nil {
+		return &Value{Type: IntType, Int: i, IntBase: intBase}, nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		if n, ok := new(big.Int).SetString(digits, base); ok {
+			return &Value{Type: IntType, BigInt: n, IntBase: intBase}, nil
+		}
+	}
+	return nil, fmt.Errorf("Cannot parse base %d integer: %q", base, digits)
+}
+
+// radix returns the base of a number literal, and its sign and digits without any 0x or 0b prefix.
+func radix(lit string) (int, string) {
+	sign, digits := "", lit
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		sign, digits = digits[:1], digits[1:]
+	}
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			return 16, sign + digits[2:]
+		case 'b', 'B':
+			return 2, sign + digits[2:]
+		}
+	}
+	return 10, lit
+}
+
+// found describes a token that was not what the parser expected, for error messages.
+func found(tok Token, lit string) string {
+	if tok == EOF {
+		return "EOF"
+	}
+	return fmt.Sprintf("'%s'", lit)
+}
+
+// closerError reports a container that was closed by the wrong delimiter, or not closed at all. If
+// the right delimiter follows the wrong one, the wrong one is reported as a stray.
+func (p *Parser) closerError(end Token, line, col int, tok Token, lit string) error {
+	closer, kind := "')'", "sexp"
+	switch end {
+	case CLOSE_BRACE:
+		closer, kind = "'}'", "struct"
+	case CLOSE_BRACKET:
+		closer, kind = "']'", "list"
+	}
+	if isCloser(tok) && p.peek(1)[0] == end {
+		return fmt.Errorf("unexpected %s in %s opened at %d:%d", found(tok, lit), kind, line, col)
+	}
+	return fmt.Errorf("expected %s to close %s opened at %d:%d, found %s", closer, kind, line, col, found(tok, lit))
+}
+
+func isOpener(tok Token) bool {
+	return tok == OPEN_BRACE || tok == OPEN_BRACKET || tok == OPEN_PAREN
+}
+
+func isCloser(tok Token) bool {
+	return tok == CLOSE_BRACE || tok == CLOSE_BRACKET || tok == CLOSE_PAREN
+}
+
+// container is a list, sexp, or struct still being parsed by parseEvents.
+type container struct {
+	typ   Type
+	end   Token
+	line  int //position of the opening delimiter
+	col   int
+	name  string //for structs, the name of the field whose value is being parsed
+	items int    //number of values added so far
+	comma bool   //whether a comma has followed the last value
+}
+
+func (p *Parser) openContainer(tok Token) *container {
+	line, col := p.position()
+	switch tok {
+	case OPEN_BRACE:
+		return &container{typ: StructType, end: CLOSE_BRACE, line: line, col: col}
+	case OPEN_BRACKET:
+		return &container{typ: ListType, end: CLOSE_BRACKET, line: line, col: col}
+	default:
+		return &container{typ: SexpType, end: CLOSE_PAREN, line: line, col: col}
+	}
+}
+
+// added records that a value has been parsed in the container.
+func (c *container) added() {
+	c.items++
+	c.comma = false
+}
+
+// checkComma enforces strict comma placement, given the next token in the container.
+func (p *Parser) checkComma(c *container, tok Token) error {
+	kind := "list elements"
+	if c.typ == StructType {
+		kind = "struct fields"
+	}
+	switch {
+	case c.typ == SexpType:
+		if tok == COMMA {
+			return fmt.Errorf("Unexpected ',' in sexp")
+		}
+	case tok == COMMA:
+		if c.items == 0 || c.comma {
+			return fmt.Errorf("Unexpected ',' between %s", kind)
+		}
+	case tok == c.end:
+		if c.comma {
+			return fmt.Errorf("Unexpected trailing ',' after %s", kind)
+		}
+	case isCloser(tok):
+		//mismatched, reported by the caller
+	default:
+		if c.items > 0 && !c.comma {
+			return fmt.Errorf("Missing ',' between %s", kind)
 		}
 	}
-	return nil, fmt.Errorf("Unexpected EOF")
+	return nil
 }
 
-func (p *Parser) parseStruct() (*Value, error) {
-	fields := make([]Field, 0)
-	tok, lit := p.scanIgnoreWhitespace()
-	for tok != EOF {
-		if tok == CLOSE_BRACE {
-			return &Value{Type: StructType, Struct: fields}, nil
-		} else if tok == COMMA {
-			tok, lit = p.scanIgnoreWhitespace()
-		} else {
-			elem, err := p.parseToken(tok, lit)
-			if err != nil {
-				return nil, err
+// parseContainer parses a list, sexp, or struct whose opening delimiter has just been read.
+func (p *Parser) parseContainer(open Token) (*Value, error) {
+	var b treeBuilder
+	if err := p.parseEvents(open, &b); err != nil {
+		return nil, err
+	}
+	return b.result, nil
+}
+
+// parseEvents parses a list, sexp, or struct whose opening delimiter has just been read, reporting
+// its contents to h. Nested containers are kept on an explicit stack rather than parsed
+// recursively, so the depth of the input is not limited by the goroutine stack.
+func (p *Parser) parseEvents(open Token, h EventHandler) error {
+	c := p.openContainer(open)
+	beginEvent(h, c.typ)
+	stack := []*container{c}
+	defer func() { p.scanner.sexp = false }()
+	//fail stops parsing with err, unless the parser is collecting errors, in which case it records
+	//err and skips from tok to the end of the value in error, so parsing can carry on
+	fail := func(err error, tok Token) error {
+		if !p.collectError(err) {
+			return err
+		}
+		p.skipValue(tok)
+		c.added()
+		return nil
+	}
+	for {
+		if err := p.checkContext(); err != nil {
+			return err
+		}
+		p.scanner.sexp = c.typ == SexpType
+		tok, lit := p.scan()
+		if p.Strict && tok != EOF {
+			if err := p.checkComma(c, tok); err != nil && !p.collectError(err) {
+				return err
 			}
-			if elem.Type != SymbolType && elem.Type != StringType {
-				return nil, fmt.Errorf("Invalid struct field name: %v", elem)
+		}
+		if tok == c.end {
+			endEvent(h, c.typ)
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return nil
 			}
-			var field Field
-			field.Name = elem.Text
-			tok, lit = p.scanIgnoreWhitespace()
+			c = stack[len(stack)-1]
+			c.added()
+			continue
+		}
+		if tok == EOF || isCloser(tok) {
+			if err := p.closerError(c.end, c.line, c.col, tok, lit); !p.collectError(err) {
+				return err
+			}
+			switch {
+			case tok == EOF:
+				//close everything still open, to keep what was parsed
+				for i := len(stack) - 1; i >= 0; i-- {
+					endEvent(h, stack[i].typ)
+				}
+				return nil
+			case closesOuter(stack, tok):
+				//close the current container, then read the closer again for the one it belongs to
+				p.unscan()
+				endEvent(h, c.typ)
+				stack = stack[:len(stack)-1]
+				c = stack[len(stack)-1]
+				c.added()
+			}
+			//otherwise it is a stray, and is skipped
+			continue
+		}
+		if tok == COMMA {
+			//commas are optional unless the parser is strict
+			c.comma = true
+			continue
+		}
+		comments := p.comments()
+		if c.typ == StructType {
+			var nameAnnotations []string
+			if p.FieldNameAnnotations {
+				var err error
+				if nameAnnotations, tok, lit, err = p.parseAnnotations(tok, lit); err != nil {
+					if err := fail(err, tok); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if p.NumericKeys && tok == NUMBER {
+				c.name = lit
+			} else {
+				name, err := p.fieldName(tok, lit)
+				if err != nil {
+					if err := fail(err, tok); err != nil {
+						return err
+					}
+					continue
+				}
+				c.name = name
+			}
+			tok, lit = p.scan()
 			if tok != COLON {
-				return nil, fmt.Errorf("Bad struct syntax, encountered %v", tok)
+				if err := fail(fmt.Errorf("Expected ':' after struct field name %q, found %s", c.name, found(tok, lit)), tok); err != nil {
+					return err
+				}
+				continue
 			}
-			elem, err = p.parse()
-			if err != nil {
-				return nil, err
+			tok, lit = p.scan()
+			if tok == CLOSE_BRACKET || tok == CLOSE_PAREN {
+				if !p.collect {
+					return p.closerError(c.end, c.line, c.col, tok, lit)
+				}
+				//reported as a mismatched closer when it is read again
+				p.unscan()
+				continue
+			}
+			if tok == EOF || tok == COMMA || tok == CLOSE_BRACE {
+				if err := fail(fmt.Errorf("Missing value for struct field %q", c.name), tok); err != nil {
+					return err
+				}
+				continue
+			}
+			h.OnFieldName(c.name)
+			if nameAnnotations != nil {
+				if nh, ok := h.(fieldNameAnnotationsHandler); ok {
+					nh.onFieldNameAnnotations(nameAnnotations)
+				}
+			}
+		}
+		annotations, tok, lit, err := p.parseAnnotations(tok, lit)
+		if err != nil {
+			if err := fail(err, tok); err != nil {
+				return err
+			}
+			continue
+		}
+		if isOpener(tok) {
+			if limit := p.maxDepth(); limit >= 0 && len(stack) >= limit {
+				if err := fail(fmt.Errorf("Maximum nesting depth exceeded: the limit is %d", limit), tok); err != nil {
+					return err
+				}
+				continue
+			}
+			leading(h, comments, annotations)
+			c = p.openContainer(tok)
+			beginEvent(h, c.typ)
+			stack = append(stack, c)
+			continue
+		}
+		val, err := p.parseAnnotatedScalar(annotations, tok, lit)
+		if err != nil {
+			if err := fail(err, tok); err != nil {
+				return err
+			}
+			continue
+		}
+		leading(h, comments, annotations)
+		h.OnScalar(*val)
+		c.added()
+	}
+}
+
+// fieldName parses a struct field name, which must be a symbol, including the empty symbol, or a
+// string, including a long string. Unless the parser allows them, annotations on the name are
+// rejected.
+func (p *Parser) fieldName(tok Token, lit string) (string, error) {
+	switch tok {
+	case ILLEGAL:
+		_, err := p.parseScalar(tok, lit)
+		return "", err
+	case SYMBOL, QUOTED_SYMBOL, SYMBOL_ID, STRING, LONG_STRING:
+		name, err := p.parseScalar(tok, lit)
+		if err != nil {
+			return "", err
+		}
+		if name.Type != SymbolType && name.Type != StringType {
+			//a keyword, such as null or true
+			break
+		}
+		if p.peek(1)[0] == DOUBLE_COLON {
+			return "", fmt.Errorf("Invalid struct field name '%s': field names cannot have annotations", name.Text)
+		}
+		return name.Text, nil
+	}
+	return "", fmt.Errorf("Invalid struct field name '%s': a field name must be a symbol or a string", lit)
+}
+
+// closesOuter reports whether tok is the closing delimiter of a container enclosing the innermost
+// one on the stack.
+func closesOuter(stack []*container, tok Token) bool {
+	for _, c := range stack[:len(stack)-1] {
+		if c.end == tok {
+			return true
+		}
+	}
+	return false
+}
+
+// collectError records err, and returns true, if the parser is collecting errors rather than
+// stopping at the first.
+func (p *Parser) collectError(err error) bool {
+	if !p.collect {
+		return false
+	}
+	p.errs = append(p.errs, p.syntaxError(err))
+	return true
+}
+
+// skipValue skips the rest of a value in error, from tok, up to the next ',' or closing delimiter
+// outside any container nested within it. That delimiter is left to be read again.
+func (p *Parser) skipValue(tok Token) {
+	depth := 0
+	for {
+		switch {
+		case tok == EOF:
+			p.unscan()
+			return
+		case isOpener(tok):
+			depth++
+		case isCloser(tok):
+			if depth == 0 {
+				p.unscan()
+				return
 			}
-			field.Value = *elem
-			fields = append(fields, field)
-			tok, lit = p.scanIgnoreWhitespace()
+			depth--
+		case tok == COMMA && depth == 0:
+			p.unscan()
+			return
 		}
+		tok, _ = p.scan()
 	}
-	return nil, fmt.Errorf("Unexpected EOF")
 }
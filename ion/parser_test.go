@@ -0,0 +1,1058 @@
+package ion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, src string) *Value {
+	t.Helper()
+	v, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", src, err)
+	}
+	return v
+}
+
+func parseError(src string) string {
+	_, err := Parse(strings.NewReader(src))
+	return errorString(err)
+}
+
+func TestMismatchedClosers(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"[1, 2}", "1:6: expected ']' to close list opened at 1:1, found '}'"},
+		{"[1, 2)", "1:6: expected ']' to close list opened at 1:1, found ')'"},
+		{"(a b]", "1:5: expected ')' to close sexp opened at 1:1, found ']'"},
+		{"(a b}", "1:5: expected ')' to close sexp opened at 1:1, found '}'"},
+		{"{a: 1]", "1:6: expected '}' to close struct opened at 1:1, found ']'"},
+		{"{a: 1)", "1:6: expected '}' to close struct opened at 1:1, found ')'"},
+		{"{a: [1}", "1:7: expected ']' to close list opened at 1:5, found '}'"},
+		{"[1, 2", "1:6: expected ']' to close list opened at 1:1, found EOF"},
+		{"{a: 1", "1:6: expected '}' to close struct opened at 1:1, found EOF"},
+		{"(a", "1:3: expected ')' to close sexp opened at 1:1, found EOF"},
+	}
+	for _, test := range tests {
+		if got := parseError(test.src); got != test.err {
+			t.Errorf("Parse(%q): got error %q, want %q", test.src, got, test.err)
+		}
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestHexAndBinaryExponents(t *testing.T) {
+	tests := []struct {
+		src  string
+		want int64
+	}{
+		{"0x1e", 30},
+		{"0x1E", 30},
+		{"-0x1e", -30},
+		{"0x1e5", 0x1e5},
+		{"0x1Fe5", 0x1fe5},
+		{"0x1e+5", 30},
+		{"0b1e1", 1},
+		{"0b101", 5},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.src, err)
+			continue
+		}
+		if v.Type != IntType {
+			t.Errorf("Parse(%q): got type %d, want an int", test.src, v.Type)
+		} else if v.Int != test.want {
+			t.Errorf("Parse(%q): got %d, want %d", test.src, v.Int, test.want)
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	tests := []struct {
+		src         string
+		annotations []string
+		err         string
+	}{
+		{"5", nil, ""},
+		{"a::5", []string{"a"}, ""},
+		{"a::b::c::5", []string{"a", "b", "c"}, ""},
+		{"a :: b::[1]", []string{"a", "b"}, ""},
+		{"'foo bar'::5", []string{"foo bar"}, ""},
+		{"a::'b c'::d::{}", []string{"a", "b c", "d"}, ""},
+		{"a::", nil, `1:4: Missing value after annotation "a"`},
+		{"a::b::", nil, `1:7: Missing value after annotation "b"`},
+		{"[a:: ]", nil, `1:6: Missing value after annotation "a"`},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		if got := errorString(err); got != test.err {
+			t.Errorf("Parse(%q): got error %q, want %q", test.src, got, test.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(v.Annotations, test.annotations) {
+			t.Errorf("Parse(%q): got annotations %q, want %q", test.src, v.Annotations, test.annotations)
+		}
+	}
+}
+
+func TestDeepList(t *testing.T) {
+	const depth = 100000
+	src := strings.Repeat("[", depth) + "1" + strings.Repeat("]", depth)
+	p := NewParser("", strings.NewReader(src))
+	p.MaxDepth = -1
+	v, err := p.Next()
+	if err != nil {
+		t.Fatalf("parsing a list nested %d deep: %v", depth, err)
+	}
+	for i := 0; i < depth; i++ {
+		if v.Type != ListType || len(v.Sequence) != 1 {
+			t.Fatalf("at depth %d: got %s with %d elements, want a list of one", i, v.Type, len(v.Sequence))
+		}
+		v = &v.Sequence[0]
+	}
+	if n, _ := v.AsInt(); n != 1 {
+		t.Errorf("got innermost value %s, want 1", v)
+	}
+}
+
+func TestSignedNumbers(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"-5", "-5"},
+		{"+5", "5"},
+		{"-0", "0"},
+		{"+3.2", "3.2"},
+		{"-3.2", "-3.2"},
+		{"-0.0", "-0.0"},
+		{"-0.0e0", "-0e0"},
+		{"-1.5e3", "-1500e0"},
+		{"(-1)", "(-1)"},
+		{"(- 1)", "('-' 1)"},
+		{"(a-b)", "(a '-' b)"},
+	}
+	for _, test := range tests {
+		if got := mustParse(t, test.src).String(); got != test.want {
+			t.Errorf("Parse(%q): got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestFloatExponents(t *testing.T) {
+	tests := []struct {
+		src   string
+		float float64
+		err   string
+	}{
+		{"2e3", 2000, ""},
+		{"2E3", 2000, ""},
+		{"1e10", 1e10, ""},
+		{"1.5e-3", 1.5e-3, ""},
+		{"6.022e23", 6.022e23, ""},
+		{"-2.5e+2", -250, ""},
+		{"1e", 0, `1:1: Cannot parse real number: "1e"`},
+		{"1e+", 0, `1:1: Cannot parse real number: "1e+"`},
+		{"1.5e-", 0, `1:1: Cannot parse real number: "1.5e-"`},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		if got := errorString(err); got != test.err {
+			t.Errorf("Parse(%q): got error %q, want %q", test.src, got, test.err)
+		} else if err == nil && (v.Type != FloatType || v.Float != test.float) {
+			t.Errorf("Parse(%q): got %s %v, want float %v", test.src, v.Type, v.Float, test.float)
+		}
+	}
+}
+
+func TestSpecialFloats(t *testing.T) {
+	tests := []struct {
+		src  string
+		test func(float64) bool
+	}{
+		{"nan", math.IsNaN},
+		{"+inf", func(f float64) bool { return math.IsInf(f, 1) }},
+		{"-inf", func(f float64) bool { return math.IsInf(f, -1) }},
+	}
+	for _, test := range tests {
+		for _, src := range []string{test.src, "[" + test.src + "]", "(" + test.src + ")"} {
+			v := mustParse(t, src)
+			if v.Type != FloatType {
+				v = &v.Sequence[0]
+			}
+			if v.Type != FloatType || !test.test(v.Float) {
+				t.Errorf("Parse(%q): got %s %v, want float %s", src, v.Type, v.Float, test.src)
+			}
+			if got := v.String(); got != test.src {
+				t.Errorf("Parse(%q).String(): got %s, want %s", src, got, test.src)
+			}
+		}
+	}
+}
+
+func TestTypedNulls(t *testing.T) {
+	tests := []struct {
+		src, want string
+		typ       Type
+	}{
+		{"null", "null", NullType},
+		{"null.null", "null", NullType},
+		{"null.bool", "null.bool", BoolType},
+		{"null.int", "null.int", IntType},
+		{"null.float", "null.float", FloatType},
+		{"null.decimal", "null.decimal", DecimalType},
+		{"null.timestamp", "null.timestamp", TimestampType},
+		{"null.string", "null.string", StringType},
+		{"null.symbol", "null.symbol", SymbolType},
+		{"null.blob", "null.blob", BlobType},
+		{"null.clob", "null.clob", ClobType},
+		{"null.list", "null.list", ListType},
+		{"null.sexp", "null.sexp", SexpType},
+		{"null.struct", "null.struct", StructType},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if v.Type != test.typ || !v.IsNull() {
+			t.Errorf("Parse(%q): got %s (null %v), want null %s", test.src, v.Type, v.IsNull(), test.typ)
+		}
+		if got := v.String(); got != test.want {
+			t.Errorf("Parse(%q).String(): got %s, want %s", test.src, got, test.want)
+		}
+	}
+	if got, want := parseError("null.foo"), `1:1: Invalid typed null: "null.foo"`; got != want {
+		t.Errorf("Parse(null.foo): got error %q, want %q", got, want)
+	}
+}
+
+func parseAll(t *testing.T, src string) []string {
+	t.Helper()
+	values, err := ParseAll(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ParseAll(%q): %v", src, err)
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = v.String()
+	}
+	return out
+}
+
+func TestLongStringConcatenation(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"'''abc''' '''def'''", []string{`"abcdef"`}},
+		{"'''a'''\n\t'''b'''  '''c'''", []string{`"abc"`}},
+		{"a::'''abc''' /* c */ '''def''' // x\n '''g'''", []string{`a::"abcdefg"`}},
+		{`'''abc''' "def"`, []string{`"abc"`, `"def"`}},
+		{"'''a''' x", []string{`"a"`, "x"}},
+		{"'''a''' b::'''c'''", []string{`"a"`, `b::"c"`}},
+		{"['''a''' '''b''', '''c''']", []string{`["ab", "c"]`}},
+		{"{f: '''a''' '''b''', g: '''c'''}", []string{`{f: "ab", g: "c"}`}},
+	}
+	for _, test := range tests {
+		if got := parseAll(t, test.src); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseAll(%q): got %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestStrictCommas(t *testing.T) {
+	tests := []struct {
+		src     string
+		strict  string
+		lenient string
+	}{
+		{"[1, 2]", "", "[1, 2]"},
+		{"{a: 1, b: [1, 2]}", "", "{a: 1, b: [1, 2]}"},
+		{"(a b)", "", "(a b)"},
+		{"[1 2]", "1:4: Missing ',' between list elements", "[1, 2]"},
+		{"[1,,2]", "1:4: Unexpected ',' between list elements", "[1, 2]"},
+		{"[,1]", "1:2: Unexpected ',' between list elements", "[1]"},
+		{"[1,]", "1:4: Unexpected trailing ',' after list elements", "[1]"},
+		{"{a:1 b:2}", "1:6: Missing ',' between struct fields", "{a: 1, b: 2}"},
+		{"{a:1,,b:2}", "1:6: Unexpected ',' between struct fields", "{a: 1, b: 2}"},
+		{"{a:1,}", "1:6: Unexpected trailing ',' after struct fields", "{a: 1}"},
+		{"[[1 2], 3]", "1:5: Missing ',' between list elements", "[[1, 2], 3]"},
+		{"{a: [1, {b: 1 c: 2}]}", "1:15: Missing ',' between struct fields", "{a: [1, {b: 1, c: 2}]}"},
+		{"(a, b)", "1:3: Unexpected ',' in sexp", "(a b)"},
+	}
+	for _, test := range tests {
+		_, err := ParseStrict(strings.NewReader(test.src))
+		if got := errorString(err); got != test.strict {
+			t.Errorf("ParseStrict(%q): got error %q, want %q", test.src, got, test.strict)
+		}
+		if got := mustParse(t, test.src).String(); got != test.lenient {
+			t.Errorf("Parse(%q): got %s, want %s", test.src, got, test.lenient)
+		}
+	}
+}
+
+func TestNumericAnnotations(t *testing.T) {
+	tests := []struct {
+		src     string
+		typ     Type
+		want    string
+		lenient Type
+	}{
+		{"decimal::1.5", DecimalType, "decimal::1.5", DecimalType},
+		{"float::1.5", FloatType, "float::1.5e0", DecimalType},
+		{"float::5", FloatType, "float::5e0", IntType},
+		{"decimal::5", DecimalType, "decimal::5d0", IntType},
+		{"decimal::1.5e3", DecimalType, "decimal::15d2", FloatType},
+		{"float::1.5d0", FloatType, "float::1.5e0", DecimalType},
+		{"x::decimal::1.5", DecimalType, "x::decimal::1.5", DecimalType},
+		{"x::float::1.5", FloatType, "x::float::1.5e0", DecimalType},
+		{"float::0x10", IntType, "float::0x10", IntType},
+		{"int::1.5", DecimalType, "int::1.5", DecimalType},
+		{"float::abc", SymbolType, "float::abc", SymbolType},
+	}
+	for _, test := range tests {
+		p := NewParser("", strings.NewReader(test.src))
+		p.NumericAnnotations = true
+		v, err := p.Next()
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.src, err)
+			continue
+		}
+		if v.Type != test.typ || v.String() != test.want {
+			t.Errorf("Parse(%q): got %s %s, want %s %s", test.src, v.Type, v, test.typ, test.want)
+		}
+		if v := mustParse(t, test.src); v.Type != test.lenient {
+			t.Errorf("Parse(%q) without NumericAnnotations: got %s, want %s", test.src, v.Type, test.lenient)
+		}
+	}
+}
+
+func TestSexpOperators(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []Value
+	}{
+		{"(+ 1 2)", []Value{Sym("+"), Int(1), Int(2)}},
+		{"(a . b)", []Value{Sym("a"), Sym("."), Sym("b")}},
+		{"(a.b)", []Value{Sym("a"), Sym("."), Sym("b")}},
+		{"(!= x y)", []Value{Sym("!="), Sym("x"), Sym("y")}},
+		{"(<=>@ x)", []Value{Sym("<=>@"), Sym("x")}},
+		{"(- 5)", []Value{Sym("-"), Int(5)}},
+		{"(-5)", []Value{Int(-5)}},
+		{"(a -5)", []Value{Sym("a"), Int(-5)}},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if want := NewSexp(test.want...); !v.Equal(want) {
+			t.Errorf("Parse(%q): got %s, want %s", test.src, v, want)
+		}
+	}
+	if got, want := parseError("[a.b]"), `1:3: Unexpected "."`; got != want {
+		t.Errorf("Parse([a.b]): got error %q, want %q", got, want)
+	}
+}
+
+func TestNumericKeys(t *testing.T) {
+	tests := []struct {
+		src     string
+		numeric string
+		err     string
+	}{
+		{`{1: "a"}`, `{'1': "a"}`, `1:2: Invalid struct field name '1': a field name must be a symbol or a string`},
+		{`{-1: "a"}`, `{'-1': "a"}`, `1:2: Invalid struct field name '-1': a field name must be a symbol or a string`},
+		{`{1.5: a}`, `{'1.5': a}`, `1:2: Invalid struct field name '1.5': a field name must be a symbol or a string`},
+		{`{0x10: a}`, `{'0x10': a}`, `1:2: Invalid struct field name '0x10': a field name must be a symbol or a string`},
+		{`{a: {2: b}}`, `{a: {'2': b}}`, `1:6: Invalid struct field name '2': a field name must be a symbol or a string`},
+		{`{a: 1}`, `{a: 1}`, ""},
+	}
+	for _, test := range tests {
+		if got := parseError(test.src); got != test.err {
+			t.Errorf("Parse(%s): got error %q, want %q", test.src, got, test.err)
+		}
+		p := NewParser("", strings.NewReader(test.src))
+		p.NumericKeys = true
+		v, err := p.Next()
+		if err != nil {
+			t.Errorf("Parse(%s) with NumericKeys: %v", test.src, err)
+		} else if got := v.String(); got != test.numeric {
+			t.Errorf("Parse(%s) with NumericKeys: got %s, want %s", test.src, got, test.numeric)
+		}
+	}
+}
+
+// slowReader returns its input a byte at a time, pausing before each one.
+type slowReader struct {
+	data  string
+	delay time.Duration
+}
+
+func (r *slowReader) Read(b []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	b[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
+
+func TestParseWithTimeout(t *testing.T) {
+	src := "[" + strings.Repeat("1, ", 2000) + "1]"
+	start := time.Now()
+	_, err := ParseWithTimeout(&slowReader{data: src, delay: time.Millisecond}, 20*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "Parse timed out: context deadline exceeded") {
+		t.Errorf("got error %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the timeout took %v to fire", elapsed)
+	}
+	v, err := ParseWithTimeout(strings.NewReader(src), time.Minute)
+	if err != nil {
+		t.Fatalf("ParseWithTimeout with time to spare: %v", err)
+	}
+	if n := len(v.Sequence); n != 2001 {
+		t.Errorf("got %d elements, want 2001", n)
+	}
+}
+
+func TestMultiCharOperators(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []Value
+	}{
+		{"(a => b)", []Value{Sym("a"), Sym("=>"), Sym("b")}},
+		{"(x <= y)", []Value{Sym("x"), Sym("<="), Sym("y")}},
+		{"(p && q)", []Value{Sym("p"), Sym("&&"), Sym("q")}},
+		{"(p||q)", []Value{Sym("p"), Sym("||"), Sym("q")}},
+		{"(a -> b)", []Value{Sym("a"), Sym("->"), Sym("b")}},
+		{"(== >= !=)", []Value{Sym("=="), Sym(">="), Sym("!=")}},
+		{"(a=>b)", []Value{Sym("a"), Sym("=>"), Sym("b")}},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if want := NewSexp(test.want...); !v.Equal(want) {
+			t.Errorf("Parse(%q): got %s, want %s", test.src, v, want)
+		}
+	}
+}
+
+func TestLobs(t *testing.T) {
+	tests := []struct {
+		src   string
+		typ   Type
+		bytes string
+		want  string
+	}{
+		{"{{ aGVsbG8= }}", BlobType, "hello", "{{aGVsbG8=}}"},
+		{"{{aGk=}}", BlobType, "hi", "{{aGk=}}"},
+		{"{{ aGV sbG8= }}", BlobType, "hello", "{{aGVsbG8=}}"},
+		{"{{ }}", BlobType, "", "{{}}"},
+		{`{{ "hello" }}`, ClobType, "hello", `{{"hello"}}`},
+		{`[{{aGk=}}, {{"c"}}]`, ListType, "", `[{{aGk=}}, {{"c"}}]`},
+		{"{{ !!! }}", BlobType, "", "1:1: Invalid base64 in blob: illegal base64 data at input byte 0"},
+		{"{{ aGk= ", BlobType, "", "1:1: Invalid token: unterminated lob, expected '}}'"},
+		{`{{ "x" }`, ClobType, "", "1:1: Invalid token: unterminated lob, expected '}}'"},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		if err != nil {
+			if got := err.Error(); got != test.want {
+				t.Errorf("Parse(%s): got error %q, want %s", test.src, got, test.want)
+			}
+			continue
+		}
+		if v.Type != test.typ || string(v.Bytes) != test.bytes || v.String() != test.want {
+			t.Errorf("Parse(%s): got %s %q written as %s, want %s %q written as %s", test.src, v.Type, v.Bytes, v, test.typ, test.bytes, test.want)
+		}
+		if back := mustParse(t, v.String()); !back.Equal(*v) {
+			t.Errorf("Parse(%s): %s does not read back as the same value", test.src, v)
+		}
+	}
+}
+
+func TestLookahead(t *testing.T) {
+	p := NewParser("", strings.NewReader("a :: [1 }"))
+	want := []Token{SYMBOL, DOUBLE_COLON, OPEN_BRACKET, NUMBER}
+	if got := p.peek(lookahead); !reflect.DeepEqual(got, want) {
+		t.Fatalf("peek(%d): got %v, want %v", lookahead, got, want)
+	}
+	if got := p.peek(2); !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("peek(2) after peek(%d): got %v, want %v", lookahead, got, want[:2])
+	}
+	for i, tok := range append(want, CLOSE_BRACE, EOF) {
+		if got, _ := p.scan(); got != tok {
+			t.Errorf("token %d: got %v, want %v", i, got, tok)
+		}
+	}
+}
+
+func TestLookaheadRecovery(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"[1, 2)]", "1:6: unexpected ')' in list opened at 1:1"},
+		{"[1, 2}]", "1:6: unexpected '}' in list opened at 1:1"},
+		{"{a: (1 2]}", "1:9: expected ')' to close sexp opened at 1:5, found ']'"},
+		{"(a ]", "1:4: expected ')' to close sexp opened at 1:1, found ']'"},
+		{"{a::b: 1}", "1:2: Invalid struct field name 'a': field names cannot have annotations"},
+	}
+	for _, test := range tests {
+		if got := parseError(test.src); got != test.err {
+			t.Errorf("Parse(%q): got error %q, want %q", test.src, got, test.err)
+		}
+	}
+	if got := parseAll(t, "$ion_1_0::5 $ion_1_0 6"); !reflect.DeepEqual(got, []string{"'$ion_1_0'::5", "6"}) {
+		t.Errorf("an annotation that looks like a version marker: got %q", got)
+	}
+}
+
+func TestTrailingPoint(t *testing.T) {
+	tests := []struct {
+		src     string
+		allowed string
+		typ     Type
+	}{
+		{"5.", "5.0", DecimalType},
+		{"-5.", "-5.0", DecimalType},
+		{"5.e3", "5000e0", FloatType},
+		{"5.E10", "5e10", FloatType},
+		{"5.d3", "50d2", DecimalType},
+		{"[5.]", "[5.0]", ListType},
+		{"{a: 5.}", "{a: 5.0}", StructType},
+	}
+	for _, test := range tests {
+		if got := parseError(test.src); !strings.HasSuffix(got, "a decimal point must be followed by a digit") {
+			t.Errorf("Parse(%q): got error %q, want a trailing point error", test.src, got)
+		}
+		p := NewParser("", strings.NewReader(test.src))
+		p.AllowTrailingPoint = true
+		v, err := p.Next()
+		if err != nil {
+			t.Errorf("Parse(%q) with AllowTrailingPoint: %v", test.src, err)
+		} else if v.Type != test.typ || v.String() != test.allowed {
+			t.Errorf("Parse(%q) with AllowTrailingPoint: got %s %s, want %s %s", test.src, v.Type, v, test.typ, test.allowed)
+		}
+	}
+	if got, want := parseError("5."), `1:1: Invalid number "5.": a decimal point must be followed by a digit`; got != want {
+		t.Errorf("Parse(5.): got error %q, want %q", got, want)
+	}
+}
+
+func TestParserNext(t *testing.T) {
+	src := "1 // one\n a::{b: 2} /* three */ [3]\n\n'''s''' x::y"
+	want := []string{"1", "a::{b: 2}", "[3]", `"s"`, "x::y"}
+	p := NewParser("", strings.NewReader(src))
+	for i, w := range want {
+		v, err := p.Next()
+		if err != nil {
+			t.Fatalf("value %d: %v", i, err)
+		}
+		if got := v.String(); got != w {
+			t.Errorf("value %d: got %s, want %s", i, got, w)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if v, err := p.Next(); err != io.EOF {
+			t.Errorf("at the end of the stream: got %v, %v, want io.EOF", v, err)
+		}
+	}
+	if got := parseAll(t, src); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAll: got %q, want %q", got, want)
+	}
+	if got := parseAll(t, " // nothing\n"); len(got) != 0 {
+		t.Errorf("ParseAll of a comment: got %q", got)
+	}
+}
+
+func TestSyntaxErrorPositions(t *testing.T) {
+	tests := []struct {
+		src       string
+		line, col int
+		msg       string
+	}{
+		{"{a: 1,\n  b: }", 2, 6, `Missing value for struct field "b"`},
+		{"[1,\n2,\n\t}", 3, 2, "expected ']' to close list opened at 1:1, found '}'"},
+		{"\n\n   @", 3, 4, `Unexpected "@"`},
+		{"\"abc\ndef", 1, 1, `Invalid token: unterminated string, expected '"'`},
+		{"{a: 1}\n}", 2, 1, `Unexpected "}"`},
+		{"[1 /* a\ncomment */ ,, 2]", 2, 13, "Unexpected ',' between list elements"},
+	}
+	for _, test := range tests {
+		p := NewParser("my.ion", strings.NewReader(test.src))
+		p.Strict = true
+		var err error
+		for err == nil {
+			_, err = p.Next()
+		}
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Parse(%q): got error %v, want a SyntaxError", test.src, err)
+			continue
+		}
+		want := SyntaxError{Source: "my.ion", Line: test.line, Col: test.col, Msg: test.msg}
+		if *se != want {
+			t.Errorf("Parse(%q): got %+v, want %+v", test.src, *se, want)
+		}
+		if got, want := se.Error(), fmt.Sprintf("my.ion:%d:%d: %s", test.line, test.col, test.msg); got != want {
+			t.Errorf("Parse(%q): got message %q, want %q", test.src, got, want)
+		}
+	}
+}
+
+func TestParseFileErrorSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.ion")
+	if err := os.WriteFile(path, []byte("{a: 1,\n  b: }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ParseFile(path)
+	if got, want := errorString(err), path+`:2:6: Missing value for struct field "b"`; got != want {
+		t.Errorf("ParseFile: got error %q, want %q", got, want)
+	}
+}
+
+func TestFieldNameAnnotations(t *testing.T) {
+	tests := []struct {
+		src         string
+		name        string
+		annotations []string
+		want        string
+		err         string
+	}{
+		{"{a::field: 1}", "field", []string{"a"}, "{a::field: 1}", ""},
+		{"{a::b::'f g': x::1, h: 2}", "f g", []string{"a", "b"}, "{a::b::'f g': x::1, h: 2}", ""},
+		{`{a::"s": 1}`, "s", []string{"a"}, "{a::s: 1}", ""},
+		{"{plain: 1}", "plain", nil, "{plain: 1}", ""},
+		{"{a:: : 1}", "", nil, "", `1:6: Missing value after annotation "a"`},
+		{"{a::1: 2}", "", nil, "", "1:5: Invalid struct field name '1': a field name must be a symbol or a string"},
+	}
+	for _, test := range tests {
+		p := NewParser("", strings.NewReader(test.src))
+		p.FieldNameAnnotations = true
+		v, err := p.Next()
+		if got := errorString(err); got != test.err {
+			t.Errorf("Parse(%q): got error %q, want %q", test.src, got, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		field := v.Struct[0]
+		if field.Name != test.name || !reflect.DeepEqual(field.NameAnnotations, test.annotations) {
+			t.Errorf("Parse(%q): got field %q with annotations %q, want %q with %q", test.src, field.Name, field.NameAnnotations, test.name, test.annotations)
+		}
+		if got := v.String(); got != test.want {
+			t.Errorf("Parse(%q).String(): got %s, want %s", test.src, got, test.want)
+		}
+	}
+	if got, want := parseError("{a::field: 1}"), "1:2: Invalid struct field name 'a': field names cannot have annotations"; got != want {
+		t.Errorf("an annotated field name by default: got error %q, want %q", got, want)
+	}
+}
+
+func TestBigInts(t *testing.T) {
+	tests := []struct {
+		src string
+		big bool
+	}{
+		{"9223372036854775807", false},
+		{"-9223372036854775808", false},
+		{"9223372036854775808", true},
+		{"-9223372036854775809", true},
+		{"340282366920938463463374607431768211456", true},
+		{"0xFFFFFFFFFFFFFFFFFF", true},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if v.Type != IntType || (v.BigInt != nil) != test.big {
+			t.Errorf("Parse(%s): got %s with BigInt %v, want big %v", test.src, v.Type, v.BigInt, test.big)
+		}
+		if got := v.String(); got != test.src {
+			t.Errorf("Parse(%s).String(): got %s", test.src, got)
+		}
+	}
+}
+
+func TestIntRadix(t *testing.T) {
+	tests := []struct {
+		src, want string
+		n         int64
+	}{
+		{"0xff", "0xFF", 255},
+		{"0XFF", "0xFF", 255},
+		{"-0x10", "-0x10", -16},
+		{"0b0101", "0b101", 5},
+		{"-0b1", "-0b1", -1},
+		{"0x0", "0x0", 0},
+		{"255", "255", 255},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if n, _ := v.AsInt(); n != test.n {
+			t.Errorf("Parse(%s): got %d, want %d", test.src, n, test.n)
+		}
+		if got := v.String(); got != test.want {
+			t.Errorf("Parse(%s).String(): got %s, want %s", test.src, got, test.want)
+		}
+	}
+	if got := Int(255).String(); got != "255" {
+		t.Errorf("Int(255).String(): got %s, want 255", got)
+	}
+}
+
+func TestWhitespaceDiagnostics(t *testing.T) {
+	tests := []struct {
+		src         string
+		want        string
+		diagnostics []string
+	}{
+		{"[1,\t2 ,\r\n 3]", "[1, 2, 3]", nil},
+		{"[1,\v2]", "[1, 2]", []string{"1:4: unusual whitespace: vertical tab"}},
+		{"{a:\f1}", "{a: 1}", []string{"1:4: unusual whitespace: form feed"}},
+		{"[\n\t 1,\n  2,\n \t3]", "[1, 2, 3]", []string{"2:1: indentation mixes tabs and spaces", "4:1: indentation mixes tabs and spaces"}},
+		{"[1,\t 2]", "[1, 2]", nil},
+	}
+	for _, test := range tests {
+		p := NewParser("", strings.NewReader(test.src))
+		p.CollectDiagnostics = true
+		v, err := p.Next()
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.src, err)
+			continue
+		}
+		if got := v.String(); got != test.want {
+			t.Errorf("Parse(%q): got %s, want %s", test.src, got, test.want)
+		}
+		var got []string
+		for _, d := range p.Diagnostics() {
+			got = append(got, d.String())
+		}
+		if !reflect.DeepEqual(got, test.diagnostics) {
+			t.Errorf("Parse(%q): got diagnostics %q, want %q", test.src, got, test.diagnostics)
+		}
+		p = NewParser("", strings.NewReader(test.src))
+		if _, err := p.Next(); err != nil || p.Diagnostics() != nil {
+			t.Errorf("Parse(%q) without CollectDiagnostics: got %v and diagnostics %v", test.src, err, p.Diagnostics())
+		}
+	}
+}
+
+func TestStrictLeadingZeros(t *testing.T) {
+	tests := []struct {
+		src    string
+		want   string
+		strict string
+	}{
+		{"007", "7", `1:1: Invalid number "007": leading zeros are not allowed`},
+		{"-01", "-1", `1:1: Invalid number "-01": leading zeros are not allowed`},
+		{"01.5", "1.5", `1:1: Invalid number "01.5": leading zeros are not allowed`},
+		{"0", "0", ""},
+		{"-0", "0", ""},
+		{"0.5", "0.5", ""},
+		{"0e0", "0e0", ""},
+		{"0x07", "0x7", ""},
+		{"0b01", "0b1", ""},
+	}
+	for _, test := range tests {
+		if got := mustParse(t, test.src).String(); got != test.want {
+			t.Errorf("Parse(%s): got %s, want %s", test.src, got, test.want)
+		}
+		p := NewParser("", strings.NewReader(test.src))
+		p.StrictLeadingZeros = true
+		_, err := p.Next()
+		if got := errorString(err); got != test.strict {
+			t.Errorf("Parse(%s) with StrictLeadingZeros: got error %q, want %q", test.src, got, test.strict)
+		}
+	}
+}
+
+func TestParseCollect(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+		errs []string
+	}{
+		{"[1, 2]", "[1, 2]", nil},
+		{"[1, ), 2]", "[1, 2]", []string{"1:5: expected ']' to close list opened at 1:1, found ')'"}},
+		{"{a: 1, b: ], c: 3}", "{a: 1, c: 3}", []string{"1:11: expected '}' to close struct opened at 1:1, found ']'"}},
+		{"[1, 2", "[1, 2]", []string{"1:6: expected ']' to close list opened at 1:1, found EOF"}},
+		{"{a: [1, }", "{a: [1]}", []string{"1:9: expected ']' to close list opened at 1:5, found '}'"}},
+		{"[{a:}, {b: 1, c: 2, 3}, 4, {d 1}, 5]", "[{}, {b: 1, c: 2}, 4, {}, 5]", []string{
+			`1:5: Missing value for struct field "a"`,
+			"1:21: Invalid struct field name '3': a field name must be a symbol or a string",
+			`1:31: Expected ':' after struct field name "d", found '1'`,
+		}},
+	}
+	for _, test := range tests {
+		v, errs := ParseCollect(strings.NewReader(test.src))
+		if got := v.String(); got != test.want {
+			t.Errorf("ParseCollect(%s): got %s, want %s", test.src, got, test.want)
+		}
+		var got []string
+		for _, err := range errs {
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Errorf("ParseCollect(%s): got a %T, want a *SyntaxError", test.src, err)
+			}
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(got, test.errs) {
+			t.Errorf("ParseCollect(%s): got errors %q, want %q", test.src, got, test.errs)
+		}
+	}
+}
+
+func TestMaxAnnotations(t *testing.T) {
+	chain := func(n int) string {
+		return strings.Repeat("a::", n) + "1"
+	}
+	tests := []struct {
+		src   string
+		limit int
+		err   string
+	}{
+		{chain(100), 0, ""},
+		{chain(101), 0, "1:302: Too many annotations on one value: the limit is 100"},
+		{chain(3), 2, "1:8: Too many annotations on one value: the limit is 2"},
+		{"[x::y::1, z::2]", 2, ""},
+		{chain(1000), -1, ""},
+	}
+	for _, test := range tests {
+		p := NewParser("", strings.NewReader(test.src))
+		p.MaxAnnotations = test.limit
+		_, err := p.Next()
+		if got := errorString(err); got != test.err {
+			t.Errorf("Parse with MaxAnnotations %d: got error %q, want %q", test.limit, got, test.err)
+		}
+	}
+}
+
+func TestUnderscoresInNumbers(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"1_000_000", "1000000"},
+		{"-1_0", "-10"},
+		{"0xFF_FF", "0xFFFF"},
+		{"0b1_0", "0b10"},
+		{"1_000.5", "1000.5"},
+		{"1e1_0", "1e10"},
+		{"_1", "_1"}, //a symbol
+		{"1__0", `1:1: Invalid number "1__0": an underscore must be between two digits`},
+		{"1_", `1:1: Invalid number "1_": an underscore must be between two digits`},
+		{"0x_FF", `1:1: Invalid number "0x_FF": an underscore must be between two digits`},
+		{"1_.5", `1:1: Invalid number "1_.5": an underscore must be between two digits`},
+		{"1._5", `1:1: Invalid number "1._5": an underscore must be between two digits`},
+		{"1_e2", `1:1: Invalid number "1_e2": an underscore must be between two digits`},
+		{"[1, 2_]", `1:5: Invalid number "2_": an underscore must be between two digits`},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		got := errorString(err)
+		if err == nil {
+			got = v.String()
+		}
+		if got != test.want {
+			t.Errorf("Parse(%s): got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestSkip(t *testing.T) {
+	src := `a::b::{x: "}", y: ['''])''', (1 {z: "]"})]} 42 [{}, ()] (') (' "\"") ''' a ''' ''' b ''' {{aGk=}} last`
+	tests := []struct {
+		skip bool
+		want string
+	}{
+		{true, ""},
+		{false, "42"},
+		{true, ""},
+		{true, ""},
+		{true, ""},
+		{false, "{{aGk=}}"},
+		{false, "last"},
+	}
+	p := NewParser("", strings.NewReader(src))
+	for i, test := range tests {
+		if test.skip {
+			if err := p.Skip(); err != nil {
+				t.Fatalf("value %d: Skip: %v", i, err)
+			}
+			continue
+		}
+		v, err := p.Next()
+		if err != nil || v.String() != test.want {
+			t.Fatalf("value %d: got %v, %v, want %s", i, v, err, test.want)
+		}
+	}
+	if err := p.Skip(); err != io.EOF {
+		t.Errorf("Skip at the end: got %v, want io.EOF", err)
+	}
+}
+
+func TestSkipErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"[1, 2", "1:6: expected ']' to close list opened at 1:1, found EOF"},
+		{"{a: (1]}", "1:7: expected ')' to close sexp opened at 1:5, found ']'"},
+		{"]", `1:1: Unexpected "]"`},
+		{"a::", `1:4: Missing value after annotation "a"`},
+	}
+	for _, test := range tests {
+		err := NewParser("", strings.NewReader(test.src)).Skip()
+		if got := errorString(err); got != test.err {
+			t.Errorf("Skip(%s): got error %q, want %q", test.src, got, test.err)
+		}
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	src := "[" + strings.Repeat(`{a: "x"}, `, 2000) + "1]"
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	_, err := ParseContext(ctx, &slowReader{data: src, delay: 100 * time.Microsecond})
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "Parse canceled: context canceled") {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the cancellation took %v to take effect", elapsed)
+	}
+	_, err = ParseContext(ctx, strings.NewReader(src))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("with a context canceled beforehand: got error %v, want context.Canceled", err)
+	}
+	v, err := ParseContext(context.Background(), strings.NewReader(src))
+	if err != nil || len(v.Sequence) != 2001 {
+		t.Errorf("with a live context: got %v, want 2001 elements", err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	nested := func(n int, open, close string) string {
+		return strings.Repeat(open, n) + strings.Repeat(close, n)
+	}
+	tests := []struct {
+		src   string
+		limit int
+		err   string
+	}{
+		{strings.Repeat("[", 10000), 0, "1:201: Maximum nesting depth exceeded: the limit is 200"},
+		{nested(200, "[", "]"), 0, ""},
+		{nested(201, "(", ")"), 0, "1:201: Maximum nesting depth exceeded: the limit is 200"},
+		{nested(201, "{a:", "}"), 0, "1:601: Maximum nesting depth exceeded: the limit is 200"},
+		{"[[1], {a: [2]}]", 2, "1:11: Maximum nesting depth exceeded: the limit is 2"},
+		{nested(1000, "[", "]"), -1, ""},
+	}
+	for _, test := range tests {
+		p := NewParser("", strings.NewReader(test.src))
+		p.MaxDepth = test.limit
+		_, err := p.Next()
+		if got := errorString(err); got != test.err {
+			t.Errorf("Parse(%.20s...) with MaxDepth %d: got error %q, want %q", test.src, test.limit, got, test.err)
+		}
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`{a: 1}`, `{a: 1}`},
+		{`{'a b': 1}`, `{'a b': 1}`},
+		{`{"a": 1}`, `{a: 1}`},
+		{`{'''long''': 1}`, `{long: 1}`},
+		{`{'''''': 1}`, `{'': 1}`},
+		{`{'': 1}`, `{'': 1}`},
+		{`{$10: 1}`, `{'$10': 1}`},
+		{`{null: 1}`, `1:2: Invalid struct field name 'null': a field name must be a symbol or a string`},
+		{`{true: 1}`, `1:2: Invalid struct field name 'true': a field name must be a symbol or a string`},
+		{`{1.5: 1}`, `1:2: Invalid struct field name '1.5': a field name must be a symbol or a string`},
+		{`{[a]: 1}`, `1:2: Invalid struct field name '[': a field name must be a symbol or a string`},
+		{`{ {b: 1}: 1}`, `1:3: Invalid struct field name '{': a field name must be a symbol or a string`},
+		{`{a::b: 1}`, `1:2: Invalid struct field name 'a': field names cannot have annotations`},
+		{`{'x'::"y": 1}`, `1:2: Invalid struct field name 'x': field names cannot have annotations`},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		got := errorString(err)
+		if err == nil {
+			got = v.String()
+		}
+		if got != test.want {
+			t.Errorf("Parse(%s): got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestVersionMarker(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"$ion_1_0 {a: 1}", []string{"{a: 1}"}},
+		{"$ion_1_0", []string{}},
+		{"$ion_1_0 1 $ion_2_13 2", []string{"1", "2"}},
+		{"[$ion_1_0]", []string{"['$ion_1_0']"}},
+		{"{a: $ion_1_0}", []string{"{a: '$ion_1_0'}"}},
+		{"'$ion_1_0' 1", []string{"'$ion_1_0'", "1"}},
+		{"a::$ion_1_0", []string{"a::'$ion_1_0'"}},
+		{"$ion_1 $ion_x_0 $ion_1_0_0", []string{"$ion_1", "$ion_x_0", "$ion_1_0_0"}},
+	}
+	for _, test := range tests {
+		if got := parseAll(t, test.src); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseAll(%s): got %q, want %q", test.src, got, test.want)
+		}
+	}
+	p := NewParser("", strings.NewReader("$ion_1_0 first"))
+	if v, err := p.Next(); err != nil || v.String() != "first" {
+		t.Errorf("Next after a version marker: got %v, %v, want first", v, err)
+	}
+	for _, v := range []string{"['$ion_1_0']", "'$ion_1_0'"} {
+		if got := mustParse(t, v).String(); got != v {
+			t.Errorf("round trip of %s: got %s", v, got)
+		}
+	}
+}
+
+func TestRadixPoint(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"0x1F", "0x1F"},
+		{"-0x1F", "-0x1F"},
+		{"0b1010", "0b1010"},
+		{"0x1e5", "0x1E5"},
+		{"0x1.5", `1:1: Invalid number "0x1.5": hexadecimal literals may not contain a decimal point`},
+		{"0x.5", `1:1: Invalid number "0x.5": hexadecimal literals may not contain a decimal point`},
+		{"0b10.1", `1:1: Invalid number "0b10.1": binary literals may not contain a decimal point`},
+		{"[1, 0X1.0]", `1:5: Invalid number "0X1.0": hexadecimal literals may not contain a decimal point`},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		got := errorString(err)
+		if err == nil {
+			got = v.String()
+		}
+		if got != test.want {
+			t.Errorf("Parse(%s): got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
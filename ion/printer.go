@@ -0,0 +1,510 @@
+package ion
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	colorReset      = "\x1b[0m"
+	colorKeyword    = "\x1b[1m"
+	colorNumber     = "\x1b[36m"
+	colorString     = "\x1b[32m"
+	colorSymbol     = "\x1b[33m"
+	colorField      = "\x1b[34m"
+	colorAnnotation = "\x1b[35m"
+)
+
+// PrettyStyle selects how Formatted lays out values that do not fit on one line.
+type PrettyStyle int
+
+const (
+	// CompactStyle writes a value on a single line, the same as String.
+	CompactStyle PrettyStyle = iota
+	// HangingStyle wraps values that would run past the Width column. A struct that does not fit
+	// gets one field per line. A list or sexp that does not fit starts right after its field name,
+	// and its elements are filled onto lines that hang one indent deeper than the field name.
+	HangingStyle
+	// BlockStyle puts every struct field and list or sexp element on its own line, indented one
+	// level deeper than its container, whatever the Width. Empty containers stay as {}, [], or ().
+	// It is the only style that writes out the Comments of values, each on its own line before the
+	// value (or its field name).
+	BlockStyle
+)
+
+// WriteOptions controls the text produced by Formatted.
+type WriteOptions struct {
+	Style  PrettyStyle
+	Width  int    //the column to wrap at, 80 if zero
+	Indent string //added at each level of nesting, two spaces if empty
+
+	// QuoteSymbols writes every symbol, annotation, and field name in single quotes, rather than
+	// only those that need them.
+	QuoteSymbols bool
+}
+
+// printer accumulates the text form of values.
+type printer struct {
+	buf       bytes.Buffer
+	color     bool
+	opts      WriteOptions
+	lineStart int       //offset in buf of the start of the current line
+	w         io.Writer //if set, buf is written out to w whenever it has grown past flushSize
+	n         int64     //bytes written to w
+	err       error     //the first error writing to w
+}
+
+// MarshalText returns the same text as String, to implement encoding.TextMarshaler.
+func (v Value) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// UnmarshalText parses text holding a single value, which may have whitespace and comments around
+// it, into v. It implements encoding.TextUnmarshaler. Empty text, or anything after the value, is
+// an error.
+func (v *Value) UnmarshalText(text []byte) error {
+	val, err := parseOne(string(text))
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		return fmt.Errorf("Cannot unmarshal text with no value")
+	}
+	*v = *val
+	return nil
+}
+
+// flushSize is how much text a printer writing to an io.Writer holds before writing it out.
+const flushSize = 4096
+
+// WriteTo writes the text form of the value to w, the same text String returns, without holding
+// all of it in memory at once. It implements io.WriterTo.
+func (v Value) WriteTo(w io.Writer) (int64, error) {
+	p := printer{w: w}
+	p.print(v)
+	p.flush()
+	return p.n, p.err
+}
+
+// flush writes out the text accumulated so far, if the printer is writing to an io.Writer.
+func (p *printer) flush() {
+	if p.w == nil {
+		return
+	}
+	if p.err == nil {
+		n, err := p.w.Write(p.buf.Bytes())
+		p.n += int64(n)
+		p.err = err
+	}
+	p.buf.Reset()
+}
+
+func (p *printer) token(color, s string) {
+	if p.color {
+		p.buf.WriteString(color)
+		p.buf.WriteString(s)
+		p.buf.WriteString(colorReset)
+	} else {
+		p.buf.WriteString(s)
+	}
+}
+
+func (p *printer) print(v Value) {
+	p.annotate(v)
+	if v.Null && v.Type != NullType {
+		p.token(colorKeyword, "null."+v.Type.String())
+		return
+	}
+	switch v.Type {
+	case NullType:
+		p.token(colorKeyword, "null")
+	case BoolType:
+		if v.Int == 0 {
+			p.token(colorKeyword, "false")
+		} else {
+			p.token(colorKeyword, "true")
+		}
+	case IntType:
+		p.token(colorNumber, intToString(v))
+	case FloatType:
+		p.token(colorNumber, floatToString(v.Float))
+	case DecimalType:
+		p.token(colorNumber, v.Decimal.String())
+	case StringType:
+		p.token(colorString, quoteText(v.Text, '"'))
+	case SymbolType:
+		if v.SymbolID {
+			p.token(colorSymbol, v.Text)
+		} else {
+			p.token(colorSymbol, p.symbol(v.Text))
+		}
+	case TimestampType:
+		p.token(colorNumber, formatTimestamp(v.Time, v.Precision))
+	case BlobType:
+		p.token(colorString, "{{"+base64.StdEncoding.EncodeToString(v.Bytes)+"}}")
+	case ClobType:
+		p.token(colorString, "{{"+clobToString(v.Bytes)+"}}")
+	case StructType:
+		p.printStruct(v.Struct)
+	case ListType:
+		p.printSequence(v.Sequence, '[', ',', ']')
+	case SexpType:
+		p.printSequence(v.Sequence, '(', 0, ')')
+	default:
+		//every Type is handled above, so this is only reached for an invalid one
+		p.buf.WriteString(v.Type.String())
+	}
+	if p.buf.Len() >= flushSize {
+		p.flush()
+	}
+}
+
+// Formatted returns the text form of the value, laid out according to the options.
+func (v Value) Formatted(opts WriteOptions) string {
+	if opts.Width <= 0 {
+		opts.Width = 80
+	}
+	if opts.Indent == "" {
+		opts.Indent = "  "
+	}
+	p := printer{opts: opts}
+	switch opts.Style {
+	case HangingStyle:
+		p.hang(v, 0)
+	case BlockStyle:
+		p.comments(v, 0)
+		p.block(v, 0)
+	default:
+		p.print(v)
+	}
+	return p.buf.String()
+}
+
+func (p *printer) column() int {
+	return p.buf.Len() - p.lineStart
+}
+
+func (p *printer) newline(depth int) {
+	p.buf.WriteByte('\n')
+	p.lineStart = p.buf.Len()
+	for i := 0; i < depth; i++ {
+		p.buf.WriteString(p.opts.Indent)
+	}
+}
+
+// hang writes the value in HangingStyle, starting at the current column of a line indented depth levels.
+func (p *printer) hang(v Value, depth int) {
+	text := v.String()
+	if p.column()+len(text) <= p.opts.Width || v.Null {
+		p.buf.WriteString(text)
+		return
+	}
+	switch v.Type {
+	case StructType:
+		if len(v.Struct) == 0 {
+			break
+		}
+		p.annotate(v)
+		p.expandStruct(v.Struct, depth, p.hang)
+		return
+	case ListType, SexpType:
+		if len(v.Sequence) == 0 {
+			break
+		}
+		p.annotate(v)
+		openCh, closeCh := byte('['), byte(']')
+		if v.Type == SexpType {
+			openCh, closeCh = '(', ')'
+		}
+		p.buf.WriteByte(openCh)
+		for i, item := range v.Sequence {
+			if i > 0 {
+				if v.Type == ListType {
+					p.buf.WriteByte(',')
+				}
+				//leave room for the following comma or closing bracket
+				if p.column()+1+len(item.String())+1 > p.opts.Width {
+					p.newline(depth + 1)
+				} else {
+					p.buf.WriteByte(' ')
+				}
+			}
+			p.hang(item, depth+1)
+		}
+		p.buf.WriteByte(closeCh)
+		return
+	}
+	p.buf.WriteString(text)
+}
+
+// block writes the value in BlockStyle, on a line indented depth levels.
+func (p *printer) block(v Value, depth int) {
+	if v.Null {
+		p.print(v)
+		return
+	}
+	switch v.Type {
+	case StructType:
+		if len(v.Struct) == 0 {
+			break
+		}
+		p.annotate(v)
+		p.expandStruct(v.Struct, depth, p.block)
+		return
+	case ListType, SexpType:
+		if len(v.Sequence) == 0 {
+			break
+		}
+		p.annotate(v)
+		openCh, closeCh := byte('['), byte(']')
+		if v.Type == SexpType {
+			openCh, closeCh = '(', ')'
+		}
+		p.buf.WriteByte(openCh)
+		for i, item := range v.Sequence {
+			if i > 0 && v.Type == ListType {
+				p.buf.WriteByte(',')
+			}
+			p.newline(depth + 1)
+			p.comments(item, depth+1)
+			p.block(item, depth+1)
+		}
+		p.newline(depth)
+		p.buf.WriteByte(closeCh)
+		return
+	}
+	p.print(v)
+}
+
+// comments writes the comments of a value in BlockStyle, each followed by a new line at depth.
+func (p *printer) comments(v Value, depth int) {
+	if p.opts.Style != BlockStyle {
+		return
+	}
+	for _, comment := range v.Comments {
+		p.buf.WriteString(comment)
+		p.newline(depth)
+	}
+}
+
+// expandStruct writes the fields of a struct one per line, laying out each field's value with layout.
+func (p *printer) expandStruct(fields []Field, depth int, layout func(Value, int)) {
+	p.buf.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			p.buf.WriteByte(',')
+		}
+		p.newline(depth + 1)
+		p.comments(field.Value, depth+1)
+		p.fieldName(field)
+		p.buf.WriteString(": ")
+		layout(field.Value, depth+1)
+	}
+	p.newline(depth)
+	p.buf.WriteByte('}')
+}
+
+// intToString writes an int in the base it was parsed from, as in 0xFF or 0b101, or in base 10.
+func intToString(v Value) string {
+	var prefix string
+	switch v.IntBase {
+	case 16:
+		prefix = "0x"
+	case 2:
+		prefix = "0b"
+	default:
+		if v.BigInt != nil {
+			return v.BigInt.String()
+		}
+		return strconv.FormatInt(v.Int, 10)
+	}
+	n := v.bigInt()
+	if n.Sign() < 0 {
+		prefix = "-" + prefix
+	}
+	return prefix + strings.ToUpper(new(big.Int).Abs(n).Text(v.IntBase))
+}
+
+// floatToString writes a float with the fewest digits that read back as the same float, always with
+// an exponent, as in 1e0 or 3.14e0, since without one Ion would read it as an int or a decimal.
+func floatToString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "+inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	i := strings.IndexByte(s, 'e')
+	if i < 0 {
+		return s + "e0"
+	}
+	exp, _ := strconv.Atoi(s[i+1:])
+	return s[:i+1] + strconv.Itoa(exp)
+}
+
+func (p *printer) annotate(val Value) {
+	for _, anno := range val.Annotations {
+		p.token(colorAnnotation, p.symbol(anno))
+		p.buf.WriteString("::")
+	}
+}
+
+// fieldName writes a field's name, preceded by any annotations on it.
+func (p *printer) fieldName(field Field) {
+	for _, anno := range field.NameAnnotations {
+		p.token(colorAnnotation, p.symbol(anno))
+		p.buf.WriteString("::")
+	}
+	p.token(colorField, p.symbol(field.Name))
+}
+
+// symbol writes a symbol, quoted if it needs to be or the options ask for it.
+func (p *printer) symbol(val string) string {
+	if p.opts.QuoteSymbols {
+		return quoteText(val, '\'')
+	}
+	return symbolToString(val)
+}
+
+// symbolToString writes a symbol unquoted if it is an identifier that would read back as the same
+// symbol, and otherwise in single quotes.
+func symbolToString(val string) string {
+	if !needsQuotes(val) {
+		return val
+	}
+	return quoteText(val, '\'')
+}
+
+// quoteText writes text between quote characters, escaping the quote character, backslashes, and
+// unprintable characters so that it reads back as the same text.
+func quoteText(text string, quote rune) string {
+	var buf strings.Builder
+	buf.WriteRune(quote)
+	for _, ch := range text {
+		switch {
+		case ch == quote || ch == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(ch)
+		case ch == '\n':
+			buf.WriteString("\\n")
+		case ch == '\t':
+			buf.WriteString("\\t")
+		case ch == '\r':
+			buf.WriteString("\\r")
+		case unicode.IsPrint(ch):
+			buf.WriteRune(ch)
+		case ch <= 0xffff:
+			fmt.Fprintf(&buf, "\\u%04x", ch)
+		default:
+			fmt.Fprintf(&buf, "\\U%08x", ch)
+		}
+	}
+	buf.WriteRune(quote)
+	return buf.String()
+}
+
+// needsQuotes reports whether a symbol must be quoted: when it is not an identifier, or is one
+// with another meaning unquoted, such as a keyword, a symbol ID like $10, or a version marker like
+// $ion_1_0.
+func needsQuotes(val string) bool {
+	if val == "" || isSymbolID(val) || isVersionMarker(val) {
+		return true
+	}
+	switch val {
+	case "null", "true", "false", "nan":
+		return true
+	}
+	for i, ch := range val {
+		if !isIdentifierStart(ch) && (i == 0 || !isDigit(ch)) {
+			return true
+		}
+	}
+	return false
+}
+
+// clobToString quotes the bytes of a clob, escaping anything that is not printable ASCII.
+func clobToString(b []byte) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c == '\n':
+			buf.WriteString("\\n")
+		case c == '\t':
+			buf.WriteString("\\t")
+		case c == '\r':
+			buf.WriteString("\\r")
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&buf, "\\x%02x", c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+func (p *printer) printStruct(fields []Field) {
+	p.buf.WriteRune('{')
+	for i, item := range fields {
+		if i > 0 {
+			p.buf.WriteString(", ")
+		}
+		p.fieldName(item)
+		p.buf.WriteString(": ")
+		p.print(item.Value)
+	}
+	p.buf.WriteRune('}')
+}
+
+func (p *printer) printSequence(values []Value, openChar, delimChar, closeChar rune) {
+	p.buf.WriteRune(openChar)
+	for i, item := range values {
+		if i > 0 {
+			if delimChar != 0 {
+				p.buf.WriteRune(delimChar)
+			}
+			p.buf.WriteRune(' ')
+		}
+		p.print(item)
+	}
+	p.buf.WriteRune(closeChar)
+}
+
+// WriteSorted writes the values to w in the order defined by Compare, one per line, so that the
+// same set of values always produces the same output. Values that Compare considers equal but that
+// print differently (such as structs with reordered fields) are ordered by their text.
+func WriteSorted(w io.Writer, values []Value) error {
+	lines := make([]string, len(values))
+	sorted := make([]int, len(values))
+	for i, v := range values {
+		lines[i] = v.String()
+		sorted[i] = i
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := Compare(values[sorted[i]], values[sorted[j]]); c != 0 {
+			return c < 0
+		}
+		return lines[sorted[i]] < lines[sorted[j]]
+	})
+	for _, i := range sorted {
+		if _, err := io.WriteString(w, lines[i]+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+package ion
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestColorString(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`"hi"`, colorString + `"hi"` + colorReset},
+		{"42", colorNumber + "42" + colorReset},
+		{"1.5", colorNumber + "1.5" + colorReset},
+		{"sym", colorSymbol + "sym" + colorReset},
+		{"true", colorKeyword + "true" + colorReset},
+		{"null.int", colorKeyword + "null.int" + colorReset},
+		{"a::1", colorAnnotation + "a" + colorReset + "::" + colorNumber + "1" + colorReset},
+		{"{f: 1}", "{" + colorField + "f" + colorReset + ": " + colorNumber + "1" + colorReset + "}"},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if got := v.ColorString(true); got != test.want {
+			t.Errorf("%s.ColorString(true): got %q, want %q", test.src, got, test.want)
+		}
+		if got, want := v.ColorString(false), v.String(); got != want {
+			t.Errorf("%s.ColorString(false): got %q, want %q", test.src, got, want)
+		}
+	}
+}
+
+func TestWriteSortedIsOrderIndependent(t *testing.T) {
+	values := mustParse(t, `({b: 2, a: 1} "x" 3 {a: 1, b: 2} [1] null 1.5e0 b a)`).Sequence
+	orders := [][]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8},
+		{8, 7, 6, 5, 4, 3, 2, 1, 0},
+		{3, 0, 5, 8, 1, 6, 2, 7, 4},
+	}
+	var first string
+	for i, order := range orders {
+		shuffled := make([]Value, len(order))
+		for j, k := range order {
+			shuffled[j] = values[k]
+		}
+		var buf bytes.Buffer
+		if err := WriteSorted(&buf, shuffled); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			first = buf.String()
+		} else if got := buf.String(); got != first {
+			t.Errorf("order %v:\ngot  %q\nwant %q", order, got, first)
+		}
+	}
+	if n := strings.Count(first, "\n"); n != len(values) {
+		t.Errorf("got %d lines, want %d", n, len(values))
+	}
+}
+
+func TestHangingStyle(t *testing.T) {
+	const src = `{name: "config", hosts: [alpha, bravo, charlie, delta, echo, foxtrot, golf, hotel, india], port: 80}`
+	tests := []struct {
+		opts WriteOptions
+		want string
+	}{
+		{WriteOptions{Style: HangingStyle, Width: 40}, `{
+  name: "config",
+  hosts: [alpha, bravo, charlie, delta,
+    echo, foxtrot, golf, hotel, india],
+  port: 80
+}`},
+		{WriteOptions{Style: HangingStyle, Width: 40, Indent: "\t"}, `{
+	name: "config",
+	hosts: [alpha, bravo, charlie, delta,
+		echo, foxtrot, golf, hotel, india],
+	port: 80
+}`},
+		{WriteOptions{Style: HangingStyle}, `{
+  name: "config",
+  hosts: [alpha, bravo, charlie, delta, echo, foxtrot, golf, hotel, india],
+  port: 80
+}`},
+		{WriteOptions{Style: HangingStyle, Width: 200}, src},
+	}
+	v := mustParse(t, src)
+	for _, test := range tests {
+		if got := v.Formatted(test.opts); got != test.want {
+			t.Errorf("Formatted(%+v):\ngot\n%s\nwant\n%s", test.opts, got, test.want)
+		}
+	}
+}
+
+func TestPrettyString(t *testing.T) {
+	tests := []struct {
+		src, indent, want string
+	}{
+		{"[]", "  ", "[]"},
+		{"{}", "  ", "{}"},
+		{"5", "  ", "5"},
+		{"a::[1]", "  ", "a::[\n  1\n]"},
+		{`{a: 1, b: [1, {}], c: x::{d: ()}, e: (f g)}`, "  ", `{
+  a: 1,
+  b: [
+    1,
+    {}
+  ],
+  c: x::{
+    d: ()
+  },
+  e: (
+    f
+    g
+  )
+}`},
+		{"{a: [1]}", "\t", "{\n\ta: [\n\t\t1\n\t]\n}"},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if got := v.PrettyString(test.indent); got != test.want {
+			t.Errorf("PrettyString(%s):\ngot\n%s\nwant\n%s", test.src, got, test.want)
+		}
+		if back := mustParse(t, test.want); !back.Equal(*v) {
+			t.Errorf("PrettyString(%s) parses back as %s", test.src, back)
+		}
+	}
+}
+
+func TestSymbolQuoting(t *testing.T) {
+	tests := []struct {
+		sym, want string
+	}{
+		{"foo", "foo"},
+		{"_a1$", "_a1$"},
+		{"$name", "$name"},
+		{"a b", "'a b'"},
+		{"1a", "'1a'"},
+		{"", "''"},
+		{"null", "'null'"},
+		{"true", "'true'"},
+		{"false", "'false'"},
+		{"nan", "'nan'"},
+		{"$10", "'$10'"},
+		{"$ion_1_0", "'$ion_1_0'"},
+		{"a-b", "'a-b'"},
+		{"é", "'é'"},
+	}
+	for _, test := range tests {
+		if got := Sym(test.sym).String(); got != test.want {
+			t.Errorf("Sym(%q).String(): got %s, want %s", test.sym, got, test.want)
+		}
+		field := NewStruct().Field(test.sym, Int(1))
+		if got, want := field.String(), "{"+test.want+": 1}"; got != want {
+			t.Errorf("a field named %q: got %s, want %s", test.sym, got, want)
+		}
+		anno := Int(1).Annotate(test.sym)
+		if got, want := anno.String(), test.want+"::1"; got != want {
+			t.Errorf("an annotation %q: got %s, want %s", test.sym, got, want)
+		}
+	}
+}
+
+func TestEscapedOutputRoundTrips(t *testing.T) {
+	tests := []struct {
+		text, symbol, str string
+	}{
+		{"it's", `'it\'s'`, `"it's"`},
+		{`say "hi"`, `'say "hi"'`, `"say \"hi\""`},
+		{`a\b`, `'a\\b'`, `"a\\b"`},
+		{"tab\there", `'tab\there'`, `"tab\there"`},
+		{"line\nbreak", `'line\nbreak'`, `"line\nbreak"`},
+		{"bell\a", `'bell\u0007'`, `"bell\u0007"`},
+		{"\x00\x7f", `'\u0000\u007f'`, `"\u0000\u007f"`},
+		{"é", "'é'", `"é"`},
+	}
+	for _, test := range tests {
+		for _, v := range []Value{Sym(test.text), Str(test.text)} {
+			want := test.str
+			if v.Type == SymbolType {
+				want = test.symbol
+			}
+			got := v.String()
+			if got != want {
+				t.Errorf("%s %q: got %s, want %s", v.Type, test.text, got, want)
+			}
+			if back := mustParse(t, got); back.Type != v.Type || back.Text != test.text {
+				t.Errorf("%s parses back as %s %q, want %q", got, back.Type, back.Text, test.text)
+			}
+		}
+	}
+}
+
+type failingWriter struct {
+	n int //bytes to accept before failing
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if len(b) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errors.New("disk full")
+	}
+	w.n -= len(b)
+	return len(b), nil
+}
+
+func TestWriteTo(t *testing.T) {
+	large := "[" + strings.Repeat(`{name: "x\ty", n: 1.5, t: 2020-01-01T, tags: [a, 'b c', (+ 1)]}, `, 500) + "]"
+	for _, src := range []string{"null", `"café"`, "a::b::{x: [1, 2e0, 3d1], 'y z': {{aGk=}}}", large} {
+		v := mustParse(t, src)
+		var buf bytes.Buffer
+		n, err := v.WriteTo(&buf)
+		if err != nil {
+			t.Errorf("WriteTo(%.40s): %v", src, err)
+			continue
+		}
+		if want := v.String(); buf.String() != want || n != int64(len(want)) {
+			t.Errorf("WriteTo(%.40s): wrote %d bytes %.60q, want %d bytes %.60q", src, n, buf.String(), len(want), want)
+		}
+	}
+	v := mustParse(t, large)
+	n, err := v.WriteTo(&failingWriter{n: 5000})
+	if err == nil || err.Error() != "disk full" || n != 5000 {
+		t.Errorf("WriteTo a failing writer: got %d, %v, want 5000, disk full", n, err)
+	}
+}
+
+func TestKeepComments(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"// a\n1", "// a\n1"},
+		{
+			"// top\n{\n  // the name\n  name: \"x\", /* old */ /* older */ port: 80,\n  list: [\n    // first\n    1,\n    2 // dropped\n  ]\n}",
+			"// top\n{\n  // the name\n  name: \"x\",\n  /* old */\n  /* older */\n  port: 80,\n  list: [\n    // first\n    1,\n    2\n  ]\n}",
+		},
+		{"[/* c */ a::1]", "[\n  /* c */\n  a::1\n]"},
+	}
+	for _, test := range tests {
+		p := NewParser("", strings.NewReader(test.src))
+		p.KeepComments = true
+		v, err := p.Next()
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.src, err)
+			continue
+		}
+		if got := v.PrettyString("  "); got != test.want {
+			t.Errorf("Parse(%q).PrettyString:\ngot  %q\nwant %q", test.src, got, test.want)
+		}
+		v = mustParse(t, test.src)
+		if v.Comments != nil || strings.Contains(v.PrettyString("  "), "/") {
+			t.Errorf("Parse(%q) without KeepComments kept %q", test.src, v.Comments)
+		}
+	}
+}
+
+func TestTextMarshaler(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		err  string
+	}{
+		{"{a: [1, b]}", "{a: [1, b]}", ""},
+		{"  1  \n// done\n", "1", ""},
+		{"/* x */ a::\"s\" /* y */", `a::"s"`, ""},
+		{"", "", "Cannot unmarshal text with no value"},
+		{"// nothing", "", "Cannot unmarshal text with no value"},
+		{"1 2", "", `1:3: Unexpected "2" after value`},
+		{"{a: 1}\n[b]", "", `2:1: Unexpected "[" after value`},
+	}
+	for _, test := range tests {
+		var v Value
+		err := v.UnmarshalText([]byte(test.text))
+		if got := errorString(err); got != test.err {
+			t.Errorf("UnmarshalText(%q): got error %q, want %q", test.text, got, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		b, err := v.MarshalText()
+		if err != nil || string(b) != test.want {
+			t.Errorf("UnmarshalText(%q) then MarshalText: got %q, %v, want %q", test.text, b, err, test.want)
+		}
+	}
+}
+
+func TestFloatFormatting(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"1.0", "1.0"}, //a decimal, so its digits are kept
+		{"1.0e0", "1e0"},
+		{"3.14000e0", "3.14e0"},
+		{"100000000.0e0", "1e8"},
+		{"123456789e0", "1.23456789e8"},
+		{"0.1e0", "0.1e0"},
+		{"1.5e-7", "1.5e-7"},
+		{"-0e0", "-0e0"},
+		{"nan", "nan"},
+		{"+inf", "+inf"},
+		{"-inf", "-inf"},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		got := v.String()
+		if got != test.want {
+			t.Errorf("Parse(%s).String(): got %s, want %s", test.src, got, test.want)
+		}
+		back := mustParse(t, got)
+		if back.Type != v.Type || !back.Equal(*v) {
+			t.Errorf("Parse(%s).String() = %s reads back as %s %s", test.src, got, back.Type, back)
+		}
+	}
+	for _, f := range []float64{1, 0.1, 1.0 / 3, 1e100, 5e-324, 123456789012345678} {
+		s := Value{Type: FloatType, Float: f}.String()
+		if v := mustParse(t, s); v.Type != FloatType || v.Float != f {
+			t.Errorf("float %v is written as %s, which reads back as %s %s", f, s, v.Type, v)
+		}
+	}
+}
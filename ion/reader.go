@@ -0,0 +1,137 @@
+package ion
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Reader reads a stream of top-level values, one at a time.
+type Reader struct {
+	parser *Parser
+
+	// StripTopAnnotation, if not empty, is a document annotation that is removed from the front of
+	// each top-level value's annotations when present. The stripped annotation is available from
+	// Annotation after each call to Next.
+	StripTopAnnotation string
+
+	annotation string
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{parser: &Parser{scanner: NewScanner(r)}}
+}
+
+// newFileReader returns a Reader over the contents of the named file.
+func newFileReader(path string, data []byte) *Reader {
+	r := NewReader(bytes.NewReader(data))
+	r.parser.source = path
+	return r
+}
+
+// Next returns the next top-level value in the stream, or io.EOF when the stream is exhausted.
+func (r *Reader) Next() (*Value, error) {
+	r.annotation = ""
+	val, err := r.parser.Next()
+	if err != nil {
+		return nil, err
+	}
+	if r.StripTopAnnotation != "" && len(val.Annotations) > 0 && val.Annotations[0] == r.StripTopAnnotation {
+		r.annotation = val.Annotations[0]
+		val.Annotations = val.Annotations[1:]
+		if len(val.Annotations) == 0 {
+			val.Annotations = nil
+		}
+	}
+	return val, nil
+}
+
+// Skip reads past the next top-level value without building it, returning io.EOF when the stream
+// is exhausted. It is cheaper than Next for values that are not wanted.
+func (r *Reader) Skip() error {
+	r.annotation = ""
+	return r.parser.Skip()
+}
+
+// Parser returns the parser the reader reads with, so its options can be set before the first call
+// to Next.
+func (r *Reader) Parser() *Parser {
+	return r.parser
+}
+
+// Document returns the index, starting at 0, of the document holding the value last returned by
+// Next, when the parser's SemicolonSeparated option divides the stream into documents.
+func (r *Reader) Document() int {
+	return r.parser.document
+}
+
+// Annotation returns the document annotation stripped from the value last returned by Next, or
+// the empty string if it did not carry one.
+func (r *Reader) Annotation() string {
+	return r.annotation
+}
+
+// ParseNDJSON parses newline-delimited JSON (or Ion), where each non-blank line holds one
+// independent value. Values are delivered on the first channel, which is closed when the input is
+// exhausted or a line fails to parse. The second channel then yields the error, if any, and is closed.
+// The values channel must be read until it is closed, or the goroutine parsing the input is left
+// blocked; a caller that may stop early should use ParseNDJSONContext and cancel its context.
+func ParseNDJSON(r io.Reader) (<-chan *Value, <-chan error) {
+	return ParseNDJSONContext(context.Background(), r)
+}
+
+// ParseNDJSONContext parses like ParseNDJSON, but stops once ctx is canceled, closing the values
+// channel and yielding the context's error, so the caller can stop reading values at any point.
+func ParseNDJSONContext(ctx context.Context, r io.Reader) (<-chan *Value, <-chan error) {
+	values := make(chan *Value)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(values)
+		reader := bufio.NewReader(r)
+		for lineno := 1; ; lineno++ {
+			line, err := reader.ReadString('\n')
+			if strings.TrimSpace(line) != "" {
+				val, perr := parseOne(strings.TrimRight(line, "\r\n"))
+				if perr != nil {
+					if se, ok := perr.(*SyntaxError); ok {
+						se.Line = lineno
+					}
+					errs <- perr
+					return
+				}
+				if val != nil {
+					select {
+					case values <- val:
+					case <-ctx.Done():
+						errs <- ctx.Err()
+						return
+					}
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					errs <- err
+				}
+				return
+			}
+		}
+	}()
+	return values, errs
+}
+
+// parseOne parses text holding at most one value, returning an error for anything after it.
+func parseOne(text string) (*Value, error) {
+	p := NewParser("", strings.NewReader(text))
+	val, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if tok, lit := p.scan(); tok != EOF {
+		return nil, p.syntaxError(fmt.Errorf("Unexpected %q after value", lit))
+	}
+	return val, nil
+}
@@ -0,0 +1,117 @@
+package ion
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReaderStripTopAnnotation(t *testing.T) {
+	src := `doc::{id: 1} doc::meta::{id: 2} {id: 3} other::{id: 4}`
+	tests := []struct {
+		value, annotation string
+	}{
+		{"{id: 1}", "doc"},
+		{"meta::{id: 2}", "doc"},
+		{"{id: 3}", ""},
+		{"other::{id: 4}", ""},
+	}
+	r := NewReader(strings.NewReader(src))
+	r.StripTopAnnotation = "doc"
+	for i, test := range tests {
+		v, err := r.Next()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if got := v.String(); got != test.value {
+			t.Errorf("record %d: got %s, want %s", i, got, test.value)
+		}
+		if got := r.Annotation(); got != test.annotation {
+			t.Errorf("record %d: got annotation %q, want %q", i, got, test.annotation)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("got %v at the end of the stream, want io.EOF", err)
+	}
+}
+
+func TestParseNDJSON(t *testing.T) {
+	tests := []struct {
+		src    string
+		values []string
+		err    string
+	}{
+		{"{\"a\": 1}\n\n[1, 2]  \n\"s\"\n", []string{"{a: 1}", "[1, 2]", `"s"`}, ""},
+		{"1\r\n  \r\n2", []string{"1", "2"}, ""},
+		{"", nil, ""},
+		{"\n\n", nil, ""},
+		{"1\n{\"a\": }\n3\n", []string{"1"}, `2:7: Missing value for struct field "a"`},
+	}
+	for _, test := range tests {
+		values, errs := ParseNDJSON(strings.NewReader(test.src))
+		var got []string
+		for v := range values {
+			got = append(got, v.String())
+		}
+		if !reflect.DeepEqual(got, test.values) {
+			t.Errorf("ParseNDJSON(%q): got %q, want %q", test.src, got, test.values)
+		}
+		if err := errorString(<-errs); err != test.err {
+			t.Errorf("ParseNDJSON(%q): got error %q, want %q", test.src, err, test.err)
+		}
+	}
+}
+
+func TestSemicolonSeparated(t *testing.T) {
+	type record struct {
+		value    string
+		document int
+	}
+	tests := []struct {
+		src     string
+		records []record
+	}{
+		{"{a: 1} {b: 2};\n{c: 3};;[4]; ", []record{{"{a: 1}", 0}, {"{b: 2}", 0}, {"{c: 3}", 1}, {"[4]", 3}}},
+		{"1;2", []record{{"1", 0}, {"2", 1}}},
+		{"(a ; b)", []record{{"(a ';' b)", 0}}},
+		{";", nil},
+	}
+	for _, test := range tests {
+		r := NewReader(strings.NewReader(test.src))
+		r.Parser().SemicolonSeparated = true
+		var got []record
+		for {
+			v, err := r.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("reading %q: %v", test.src, err)
+			}
+			got = append(got, record{v.String(), r.Document()})
+		}
+		if !reflect.DeepEqual(got, test.records) {
+			t.Errorf("reading %q: got %v, want %v", test.src, got, test.records)
+		}
+	}
+	_, err := ParseAll(strings.NewReader("1; 2"))
+	if got, want := errorString(err), `1:2: Unexpected ";"`; got != want {
+		t.Errorf("a top-level ';' by default: got error %q, want %q", got, want)
+	}
+}
+
+func TestParseNDJSONContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	values, errs := ParseNDJSONContext(ctx, strings.NewReader(strings.Repeat("1\n", 100)))
+	if v := <-values; v == nil || v.String() != "1" {
+		t.Fatalf("got first value %v, want 1", v)
+	}
+	cancel()
+	for range values {
+		//values already parsed may still arrive, until the parser sees the cancellation
+	}
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+}
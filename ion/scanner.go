@@ -3,28 +3,40 @@ package ion
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"strings"
+	"unicode"
+	"unicode/utf16"
 )
 
+// Token is the kind of a token read by a Scanner or a Tokenizer. The constants are part of the
+// public API, and keep their meanings, so code can switch on them.
 type Token int
 
 const (
-	ILLEGAL Token = iota
-	EOF
-	WHITESPACE
-	COMMA
-	COLON
-	DOUBLE_COLON
-	SYMBOL
-	STRING
-	OPEN_BRACE
-	CLOSE_BRACE
-	OPEN_BRACKET
-	CLOSE_BRACKET
-	OPEN_PAREN
-	CLOSE_PAREN
-	NUMBER
+	ILLEGAL       Token = iota // not valid Ion; the literal is the text, or a description of the problem
+	EOF                        // the end of the input
+	WHITESPACE                 // a run of whitespace, as written
+	COMMA                      // ,
+	COLON                      // :
+	DOUBLE_COLON               // :: after an annotation
+	SYMBOL                     // an identifier, a keyword such as null, or an operator in an s-expression
+	STRING                     // a "quoted" string; the literal is its text, with escapes decoded
+	OPEN_BRACE                 // {
+	CLOSE_BRACE                // }
+	OPEN_BRACKET               // [
+	CLOSE_BRACKET              // ]
+	OPEN_PAREN                 // (
+	CLOSE_PAREN                // )
+	NUMBER                     // an int, decimal, or float, as written, or +inf or -inf
+	BLOB                       // a {{ blob }}; the literal is its base64 text
+	CLOB                       // a {{ "clob" }}; the literal is its text, with escapes decoded
+	TIMESTAMP                  // a timestamp, as written
+	QUOTED_SYMBOL              // a 'quoted' symbol; the literal is its text, with escapes decoded
+	LONG_STRING                // one part of a long string; the literal is its text, with escapes decoded
+	SYMBOL_ID                  // a symbol ID such as $10
+	COMMENT                    // a // or /* */ comment, as written, if comments were asked for
 )
 
 func (t Token) String() string {
@@ -57,42 +69,132 @@ func (t Token) String() string {
 		return "CLOSE_PAREN"
 	case NUMBER:
 		return "NUMBER"
+	case BLOB:
+		return "BLOB"
+	case CLOB:
+		return "CLOB"
+	case TIMESTAMP:
+		return "TIMESTAMP"
+	case QUOTED_SYMBOL:
+		return "QUOTED_SYMBOL"
+	case LONG_STRING:
+		return "LONG_STRING"
+	case SYMBOL_ID:
+		return "SYMBOL_ID"
+	case COMMENT:
+		return "COMMENT"
 	}
 	return "ILLEGAL"
 }
 
+// isWhitespace reports whether ch is one of Ion's whitespace characters.
 func isWhitespace(ch rune) bool {
-	return ch == ' ' || ch == '\t' || ch == '\n'
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\v' || ch == '\f'
 }
 
 func isLetter(ch rune) bool {
 	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
 }
 
+// isIdentifierStart reports whether ch can begin an unquoted symbol. Later characters may also be digits.
+func isIdentifierStart(ch rune) bool {
+	return isLetter(ch) || ch == '_' || ch == '$'
+}
+
 func isDigit(ch rune) bool {
 	return (ch >= '0' && ch <= '9')
 }
 
+// isDigits reports whether s is made up of one or more decimal digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, ch := range s {
+		if !isDigit(ch) {
+			return false
+		}
+	}
+	return true
+}
+
+const operatorChars = "!#%&*+-./;<=>?@^`|~"
+
+func isOperator(ch rune) bool {
+	return ch != eof && strings.ContainsRune(operatorChars, ch)
+}
+
 var eof = rune(0)
 
 type Scanner struct {
 	r           *bufio.Reader
 	lastToken   Token
 	lastLiteral string
+	line, col   int //position of the next rune to be read
+	prevLine    int //position before the last read, restored by unread
+	prevCol     int
+	cr          bool //whether the last rune read was '\r', so that a '\n' after it is not a second newline
+	prevCR      bool
+	tokLine     int //position where the last scanned token started
+	tokCol      int
+	sexp        bool //set by the parser inside an s-expression, where operators are symbols
+	diagnose    bool //set by the parser to record unusual whitespace in diagnostics
+	comments    bool //set by the parser to return comments as COMMENT tokens rather than skip them
+	diagnostics []Diagnostic
+	started     bool //whether the first token has been scanned, after skipping any byte-order mark
+}
+
+// Diagnostic is a note about something unusual in the input that does not prevent it from being
+// parsed, such as a vertical tab, for linting.
+type Diagnostic struct {
+	Line int // 1-based
+	Col  int // 1-based
+	Msg  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Col, d.Msg)
 }
 
 func NewScanner(r io.Reader) *Scanner {
-	return &Scanner{r: bufio.NewReader(r)}
+	return &Scanner{r: bufio.NewReader(r), line: 1, col: 1}
+}
+
+// Reset makes the scanner read from r as if it were new, reusing its buffer, so that one scanner
+// can scan many inputs without allocating for each.
+func (s *Scanner) Reset(r io.Reader) {
+	s.r.Reset(r)
+	*s = Scanner{r: s.r, line: 1, col: 1}
+}
+
+// Position returns the line and column (both 1-based) where the most recently scanned token started.
+func (s *Scanner) Position() (line, col int) {
+	return s.tokLine, s.tokCol
 }
+
 func (s *Scanner) read() rune {
+	s.prevLine, s.prevCol, s.prevCR = s.line, s.col, s.cr
 	ch, _, err := s.r.ReadRune()
 	if err != nil {
 		return eof
 	}
+	switch {
+	case ch == '\n' && s.cr:
+		//the second half of a \r\n line ending, already counted
+	case ch == '\n' || ch == '\r':
+		s.line++
+		s.col = 1
+	default:
+		s.col++
+	}
+	s.cr = ch == '\r'
 	return ch
 }
 
-func (s *Scanner) unread() { _ = s.r.UnreadRune() }
+func (s *Scanner) unread() {
+	_ = s.r.UnreadRune()
+	s.line, s.col, s.cr = s.prevLine, s.prevCol, s.prevCR
+}
 
 func (s *Scanner) Unscan(tok Token, lit string) {
 	s.lastToken = tok
@@ -107,12 +209,20 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 		s.lastLiteral = ""
 		return tok, lit
 	}
+	if !s.started {
+		//a UTF-8 byte-order mark, as some editors write, is skipped at the start of the input only
+		s.started = true
+		if b, _ := s.r.Peek(len(byteOrderMark)); string(b) == byteOrderMark {
+			s.r.Discard(len(byteOrderMark))
+		}
+	}
+	s.tokLine, s.tokCol = s.line, s.col
 	ch := s.read()
 
 	if isWhitespace(ch) {
 		s.unread()
 		return s.scanWhitespace()
-	} else if isLetter(ch) {
+	} else if isIdentifierStart(ch) {
 		s.unread()
 		return s.scanIdentifier()
 	}
@@ -122,14 +232,24 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 	case eof:
 		return EOF, ""
 	case '/':
-		ch = s.read()
-		if ch == '/' {
-			s.skipLine()
+		next := s.read()
+		if next == '/' || next == '*' {
+			var text *bytes.Buffer
+			if s.comments {
+				text = bytes.NewBufferString("/")
+				text.WriteRune(next)
+			}
+			if next == '/' {
+				s.skipLine(text)
+			} else if !s.skipBlockComment(text) {
+				return ILLEGAL, "unterminated block comment"
+			}
+			if text != nil {
+				return COMMENT, text.String()
+			}
 			return s.Scan()
-		} else {
-			s.unread()
-			return ILLEGAL, "/"
 		}
+		s.unread()
 
 	case ':':
 		ch = s.read()
@@ -140,12 +260,33 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 			return COLON, ":"
 		}
 	case '\'':
-		return s.scanUntil(SYMBOL, ch)
+		if s.peekQuotes() {
+			s.read()
+			s.read()
+			return s.scanLongString()
+		}
+		return s.scanUntil(QUOTED_SYMBOL, ch)
 	case '"':
 		return s.scanUntil(STRING, ch)
+	case '-', '+':
+		next := s.read()
+		s.unread()
+		if isDigit(next) {
+			return s.scanNumber(ch)
+		}
+		if s.peekKeyword("inf") {
+			s.read()
+			s.read()
+			s.read()
+			return NUMBER, string(ch) + "inf"
+		}
 	case ',':
 		return COMMA, string(ch)
 	case '{':
+		if s.read() == '{' {
+			return s.scanLob()
+		}
+		s.unread()
 		return OPEN_BRACE, string(ch)
 	case '}':
 		return CLOSE_BRACE, string(ch)
@@ -161,27 +302,115 @@ func (s *Scanner) Scan() (tok Token, lit string) {
 	if isDigit(ch) {
 		return s.scanNumber(ch)
 	}
+	if s.sexp && isOperator(ch) {
+		return s.scanOperator(ch)
+	}
+	if string(ch) == byteOrderMark {
+		return ILLEGAL, "byte-order mark after the start of the input"
+	}
 	return ILLEGAL, string(ch)
 }
 
-func (s *Scanner) skipLine() {
+// byteOrderMark is the Unicode byte-order mark, U+FEFF.
+const byteOrderMark = "\uFEFF"
+
+// scanOperator scans a run of operator characters, which is a symbol inside an s-expression. The
+// run is as long as possible, so => and && are single symbols, but it stops before the start of a
+// comment.
+func (s *Scanner) scanOperator(first rune) (Token, string) {
+	var buf bytes.Buffer
+	buf.WriteRune(first)
+	for !s.peekCommentStart() {
+		ch := s.read()
+		if !isOperator(ch) {
+			s.unread()
+			break
+		}
+		buf.WriteRune(ch)
+	}
+	return SYMBOL, buf.String()
+}
+
+// peekCommentStart reports whether the next runes start a comment.
+func (s *Scanner) peekCommentStart() bool {
+	b, _ := s.r.Peek(2)
+	return len(b) == 2 && b[0] == '/' && (b[1] == '/' || b[1] == '*')
+}
+
+// peekKeyword reports whether the next runes spell out the given ASCII keyword, and are not
+// followed by anything that would continue it as an identifier.
+func (s *Scanner) peekKeyword(word string) bool {
+	b, _ := s.r.Peek(len(word) + 1)
+	if len(b) < len(word) || string(b[:len(word)]) != word {
+		return false
+	}
+	if len(b) > len(word) {
+		ch := rune(b[len(word)])
+		return !isLetter(ch) && !isDigit(ch) && ch != '_'
+	}
+	return true
+}
+
+// skipLine skips the rest of a line, leaving the newline to be read as whitespace. If text is not
+// nil, the rest of the line is added to it.
+func (s *Scanner) skipLine(text *bytes.Buffer) {
 	for {
-		if ch := s.read(); ch == eof || ch == '\n' {
+		ch := s.read()
+		if ch == '\n' || ch == '\r' {
+			s.unread()
+		}
+		if ch == eof || ch == '\n' || ch == '\r' {
 			break
 		}
+		if text != nil {
+			text.WriteRune(ch)
+		}
+	}
+}
+
+// skipBlockComment skips the rest of a /* ... */ comment, returning false if EOF comes first. If
+// text is not nil, the comment is added to it.
+func (s *Scanner) skipBlockComment(text *bytes.Buffer) bool {
+	for {
+		ch := s.read()
+		for ch == '*' {
+			if text != nil {
+				text.WriteRune(ch)
+			}
+			if ch = s.read(); ch == '/' {
+				if text != nil {
+					text.WriteRune(ch)
+				}
+				return true
+			}
+		}
+		if ch == eof {
+			return false
+		}
+		if text != nil {
+			text.WriteRune(ch)
+		}
 	}
 }
 
+const decimalDigits = "0123456789."
+
+// scanNumber scans a number starting with the given digit or sign; a sign is always followed by a digit.
 func (s *Scanner) scanNumber(first rune) (Token, string) {
 	var buf bytes.Buffer
 	buf.WriteRune(first)
-	digits := "0123456789."
+	if first == '-' || first == '+' {
+		first = s.read()
+		buf.WriteRune(first)
+	}
+	digits := decimalDigits
 	if ch := s.read(); ch != eof {
+		//the hex and binary digit sets must never grow exponent markers: in 0x1e the 'e' is a digit
 		if first == '0' {
-			if ch == 'x' {
+			if ch == 'x' || ch == 'X' {
 				digits = "0123456789abcdefABCDEF."
 				buf.WriteRune(ch)
-			} else if ch == 'b' {
+			} else if ch == 'b' || ch == 'B' {
 				digits = "01."
 				buf.WriteRune(ch)
 			} else {
@@ -193,8 +422,19 @@ func (s *Scanner) scanNumber(first rune) (Token, string) {
 		for {
 			if ch := s.read(); ch == eof {
 				break
-			} else if strings.Index(digits, string(ch)) >= 0 {
+			} else if strings.Index(digits, string(ch)) >= 0 || ch == '_' {
+				buf.WriteRune(ch)
+			} else if strings.ContainsRune("eEdD", ch) && digits == decimalDigits {
+				//an exponent marked with e makes a float, and with d a decimal
 				buf.WriteRune(ch)
+				if sign := s.read(); sign == '+' || sign == '-' {
+					buf.WriteRune(sign)
+				} else {
+					s.unread()
+				}
+			} else if (ch == '-' || ch == 'T') && isYear(buf.String()) {
+				buf.WriteRune(ch)
+				return s.scanTimestamp(&buf)
 			} else {
 				s.unread()
 				break
@@ -204,39 +444,40 @@ func (s *Scanner) scanNumber(first rune) (Token, string) {
 	return NUMBER, buf.String()
 }
 
+func isYear(lit string) bool {
+	return len(lit) == 4 && isDigits(lit)
+}
+
+// scanTimestamp scans the rest of a timestamp, once its year and the following '-' or 'T' have
+// been read. The literal is only checked for well-formedness by the parser.
+func (s *Scanner) scanTimestamp(buf *bytes.Buffer) (Token, string) {
+	for {
+		if ch := s.read(); ch == eof {
+			break
+		} else if isDigit(ch) || strings.IndexRune("-:.TZ+", ch) >= 0 {
+			buf.WriteRune(ch)
+		} else {
+			s.unread()
+			break
+		}
+	}
+	return TIMESTAMP, buf.String()
+}
+
 func (s *Scanner) scanUntil(tok Token, delim rune) (Token, string) {
 	var buf bytes.Buffer
-	buf.WriteRune(s.read())
-	escape := false
 	for {
 		if ch := s.read(); ch == eof {
-			break
-		} else if escape {
-			escape = false
-			switch ch {
-			case '"':
-				buf.WriteRune('"')
-			case 't':
-				buf.WriteRune('\t')
-			case 'n':
-				buf.WriteRune('\n')
-			case 'r':
-				buf.WriteRune('\r')
-			case '\n':
-				//if newline, ignore subsequent whitespace before continuing with the string
-				for {
-					if ch := s.read(); ch == eof || !isWhitespace(ch) {
-						break
-					}
-				}
-				s.unread()
-			default:
-				return ILLEGAL, "\\" + string(ch)
+			if tok == STRING {
+				return ILLEGAL, `unterminated string, expected '"'`
 			}
+			return ILLEGAL, `unterminated symbol, expected "'"`
 		} else if ch == delim {
 			break
 		} else if ch == '\\' {
-			escape = true
+			if errlit := s.scanEscape(&buf); errlit != "" {
+				return ILLEGAL, errlit
+			}
 		} else {
 			buf.WriteRune(ch)
 		}
@@ -244,22 +485,263 @@ func (s *Scanner) scanUntil(tok Token, delim rune) (Token, string) {
 	return tok, buf.String()
 }
 
-func (s *Scanner) scanWhitespace() (tok Token, lit string) {
+// scanLongString scans a long string once its opening quotes have been read. The content runs,
+// newlines included, until the closing quotes.
+func (s *Scanner) scanLongString() (Token, string) {
 	var buf bytes.Buffer
-	buf.WriteRune(s.read())
 	for {
 		if ch := s.read(); ch == eof {
+			return ILLEGAL, "unterminated long string, expected '''"
+		} else if ch == '\'' && s.peekQuotes() {
+			s.read()
+			s.read()
+			return LONG_STRING, buf.String()
+		} else if ch == '\\' {
+			if errlit := s.scanEscape(&buf); errlit != "" {
+				return ILLEGAL, errlit
+			}
+		} else if ch == '\r' {
+			//a \r\n or \r line ending is read as \n, so the text is the same whatever the file's line endings
+			if s.read() != '\n' {
+				s.unread()
+			}
+			buf.WriteByte('\n')
+		} else {
+			buf.WriteRune(ch)
+		}
+	}
+}
+
+// peekQuotes reports whether the next two runes are single quotes, which after a first single
+// quote make the delimiter of a long string.
+func (s *Scanner) peekQuotes() bool {
+	b, _ := s.r.Peek(2)
+	return string(b) == "''"
+}
+
+// scanEscape decodes the escape sequence following a backslash in a string or symbol into buf,
+// returning a description of the problem if it is invalid.
+func (s *Scanner) scanEscape(buf *bytes.Buffer) string {
+	ch := s.read()
+	if b, ok := simpleEscapes[ch]; ok {
+		buf.WriteByte(b)
+		return ""
+	}
+	switch ch {
+	case 'x':
+		r, errlit := s.scanHex(ch, 2)
+		if errlit != "" {
+			return errlit
+		}
+		buf.WriteRune(r)
+	case 'u':
+		r, errlit := s.scanUnicodeEscape()
+		if errlit != "" {
+			return errlit
+		}
+		buf.WriteRune(r)
+	case 'U':
+		r, errlit := s.scanHex(ch, 8)
+		if errlit != "" {
+			return errlit
+		}
+		if r > unicode.MaxRune || utf16.IsSurrogate(r) {
+			return fmt.Sprintf("invalid escape \\U%08x: not a valid code point", r)
+		}
+		buf.WriteRune(r)
+	case '\n', '\r':
+		//if newline, ignore subsequent whitespace before continuing with the string
+		for {
+			if ch := s.read(); ch == eof || !isWhitespace(ch) {
+				break
+			}
+		}
+		s.unread()
+	case eof:
+		return "unterminated escape sequence"
+	default:
+		return fmt.Sprintf("invalid escape \\%c", ch)
+	}
+	return ""
+}
+
+// scanLob scans the rest of a blob or clob once its opening "{{" has been read. A blob's literal is
+// its base64 text with whitespace removed, a clob's is the raw bytes of its string.
+func (s *Scanner) scanLob() (Token, string) {
+	tok := BLOB
+	var buf bytes.Buffer
+	s.skipWhitespace()
+	if ch := s.read(); ch == '"' {
+		tok = CLOB
+		if errlit := s.scanClobString(&buf); errlit != "" {
+			return ILLEGAL, errlit
+		}
+	} else {
+		s.unread()
+		for {
+			if ch := s.read(); ch == eof || ch == '}' {
+				s.unread()
+				break
+			} else if !isWhitespace(ch) {
+				buf.WriteRune(ch)
+			}
+		}
+	}
+	s.skipWhitespace()
+	if s.read() != '}' || s.read() != '}' {
+		return ILLEGAL, "unterminated lob, expected '}}'"
+	}
+	return tok, buf.String()
+}
+
+// simpleEscapes maps the character following a backslash in a string, symbol, or clob to the byte
+// it denotes. Clobs hold bytes rather than text, so the \u and \U escapes allowed in strings are
+// not accepted there.
+var simpleEscapes = map[rune]byte{
+	'a': '\a', 'b': '\b', 't': '\t', 'n': '\n', 'f': '\f', 'r': '\r', 'v': '\v',
+	'?': '?', '0': 0, '\'': '\'', '"': '"', '/': '/', '\\': '\\',
+}
+
+// scanClobString scans the body of a quoted clob string after its opening quote, returning a
+// description of the problem if it is invalid.
+func (s *Scanner) scanClobString(buf *bytes.Buffer) string {
+	for {
+		ch := s.read()
+		switch {
+		case ch == eof:
+			return "unterminated clob string"
+		case ch == '"':
+			return ""
+		case ch >= 0x80:
+			return "clob strings may only contain ASCII characters"
+		case ch != '\\':
+			buf.WriteByte(byte(ch))
+			continue
+		}
+		ch = s.read()
+		if b, ok := simpleEscapes[ch]; ok {
+			buf.WriteByte(b)
+		} else if ch == 'x' {
+			n, errlit := s.scanHex(ch, 2)
+			if errlit != "" {
+				return errlit
+			}
+			buf.WriteByte(byte(n))
+		} else if ch == 'u' || ch == 'U' {
+			return "\\" + string(ch) + " escapes are not allowed in clobs"
+		} else {
+			return "invalid clob escape \\" + string(ch)
+		}
+	}
+}
+
+// scanHex reads the n hex digits of a \x, \u, or \U escape, in either case, and returns the value
+// they encode. If a character that is not a hex digit is found, it is left unread and a description
+// of the bad escape is returned instead.
+func (s *Scanner) scanHex(escape rune, n int) (rune, string) {
+	var value rune
+	digits := ""
+	for i := 0; i < n; i++ {
+		ch := s.read()
+		d := hexValue(ch)
+		if d < 0 {
+			s.unread()
+			if ch != eof {
+				digits += string(ch)
+			}
+			return 0, fmt.Sprintf("invalid escape \\%c%s: expected %d hex digits", escape, digits, n)
+		}
+		digits += string(ch)
+		value = value*16 + d
+	}
+	return value, ""
+}
+
+// scanUnicodeEscape reads the hex digits of a \u escape. A UTF-16 high surrogate must be followed
+// by a \u escape for its low surrogate, as in JSON, and the pair is combined into one rune.
+func (s *Scanner) scanUnicodeEscape() (rune, string) {
+	r, errlit := s.scanHex('u', 4)
+	if errlit != "" || !utf16.IsSurrogate(r) {
+		return r, errlit
+	}
+	if r >= 0xDC00 {
+		return 0, fmt.Sprintf("unpaired low surrogate \\u%04x", r)
+	}
+	if s.read() != '\\' || s.read() != 'u' {
+		return 0, fmt.Sprintf("unpaired high surrogate \\u%04x", r)
+	}
+	lo, errlit := s.scanHex('u', 4)
+	if errlit != "" {
+		return 0, errlit
+	}
+	if lo < 0xDC00 || lo > 0xDFFF {
+		return 0, fmt.Sprintf("unpaired high surrogate \\u%04x", r)
+	}
+	return utf16.DecodeRune(r, lo), ""
+}
+
+func hexValue(ch rune) rune {
+	switch {
+	case ch >= '0' && ch <= '9':
+		return ch - '0'
+	case ch >= 'a' && ch <= 'f':
+		return ch - 'a' + 10
+	case ch >= 'A' && ch <= 'F':
+		return ch - 'A' + 10
+	}
+	return -1
+}
+
+func (s *Scanner) skipWhitespace() {
+	for {
+		if ch := s.read(); ch == eof || !isWhitespace(ch) {
+			s.unread()
+			return
+		}
+	}
+}
+
+func (s *Scanner) scanWhitespace() (tok Token, lit string) {
+	var buf bytes.Buffer
+	indent := s.col == 1 //whether the whitespace since the last newline is a line's indentation
+	tabs, spaces := false, false
+	for {
+		line, col := s.line, s.col
+		ch := s.read()
+		if ch == eof {
 			break
 		} else if !isWhitespace(ch) {
 			s.unread()
 			break
-		} else {
-			buf.WriteRune(ch)
+		}
+		buf.WriteRune(ch)
+		if !s.diagnose {
+			continue
+		}
+		switch ch {
+		case '\n', '\r':
+			indent, tabs, spaces = true, false, false
+		case '\t':
+			tabs = true
+		case ' ':
+			spaces = true
+		case '\v':
+			s.diagnostic(line, col, "unusual whitespace: vertical tab")
+		case '\f':
+			s.diagnostic(line, col, "unusual whitespace: form feed")
+		}
+		if indent && tabs && spaces {
+			s.diagnostic(line, 1, "indentation mixes tabs and spaces")
+			indent = false
 		}
 	}
 	return WHITESPACE, buf.String()
 }
 
+func (s *Scanner) diagnostic(line, col int, msg string) {
+	s.diagnostics = append(s.diagnostics, Diagnostic{Line: line, Col: col, Msg: msg})
+}
+
 func (s *Scanner) scanIdentifier() (tok Token, lit string) {
 	var buf bytes.Buffer
 	buf.WriteRune(s.read())
@@ -267,12 +749,38 @@ func (s *Scanner) scanIdentifier() (tok Token, lit string) {
 	for {
 		if ch := s.read(); ch == eof {
 			break
-		} else if !isLetter(ch) && !isDigit(ch) && ch != '_' {
+		} else if !isIdentifierStart(ch) && !isDigit(ch) {
 			s.unread()
 			break
 		} else {
 			_, _ = buf.WriteRune(ch)
 		}
 	}
+	if buf.String() == "null" {
+		//a typed null, such as null.int, is a single token
+		if ch := s.read(); ch == '.' {
+			buf.WriteRune(ch)
+			for {
+				if ch := s.read(); ch == eof {
+					break
+				} else if !isLetter(ch) {
+					s.unread()
+					break
+				} else {
+					buf.WriteRune(ch)
+				}
+			}
+		} else {
+			s.unread()
+		}
+	}
+	if isSymbolID(buf.String()) {
+		return SYMBOL_ID, buf.String()
+	}
 	return SYMBOL, buf.String()
 }
+
+// isSymbolID reports whether an identifier is a symbol ID, a '$' followed only by digits, such as $10.
+func isSymbolID(lit string) bool {
+	return len(lit) > 1 && lit[0] == '$' && isDigits(lit[1:])
+}
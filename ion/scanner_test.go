@@ -0,0 +1,324 @@
+package ion
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestClobEscapes(t *testing.T) {
+	tests := []struct {
+		src   string
+		bytes []byte
+		err   string
+	}{
+		{`{{ "a\xFFb" }}`, []byte{'a', 0xff, 'b'}, ""},
+		{`{{ "\x00\x7f" }}`, []byte{0, 0x7f}, ""},
+		{`{{ "\n\t\0\"\\" }}`, []byte{'\n', '\t', 0, '"', '\\'}, ""},
+		{`{{ "\u00e9" }}`, nil, "1:1: Invalid token: \\u escapes are not allowed in clobs"},
+		{`{{ "\U000000e9" }}`, nil, "1:1: Invalid token: \\U escapes are not allowed in clobs"},
+		{`{{ "\xF" }}`, nil, "1:1: Invalid token: invalid escape \\xF\": expected 2 hex digits"},
+		{`{{ "é" }}`, nil, "1:1: Invalid token: clob strings may only contain ASCII characters"},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("Parse(%s): got error %v, want %q", test.src, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%s): %v", test.src, err)
+		} else if v.Type != ClobType || !bytes.Equal(v.Bytes, test.bytes) {
+			t.Errorf("Parse(%s): got %s %q, want clob %q", test.src, v.Type, v.Bytes, test.bytes)
+		}
+	}
+}
+
+func TestHexEscapes(t *testing.T) {
+	tests := []struct {
+		src, text, err string
+	}{
+		{`"\x4A"`, "J", ""},
+		{`"\x4a"`, "J", ""},
+		{`"\xFF"`, "\u00ff", ""},
+		{`"\u00E9"`, "é", ""},
+		{`"\u00e9"`, "é", ""},
+		{`"\U0001F600"`, "\U0001F600", ""},
+		{`'\x4a\u00E9'`, "Jé", ""},
+		{`"\xG0"`, "", "1:1: Invalid token: invalid escape \\xG: expected 2 hex digits"},
+		{`"\x4"`, "", "1:1: Invalid token: invalid escape \\x4\": expected 2 hex digits"},
+		{`"\u00G9"`, "", "1:1: Invalid token: invalid escape \\u00G: expected 4 hex digits"},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		if got := errorString(err); got != test.err {
+			t.Errorf("Parse(%s): got error %q, want %q", test.src, got, test.err)
+		} else if err == nil && v.Text != test.text {
+			t.Errorf("Parse(%s): got %q, want %q", test.src, v.Text, test.text)
+		}
+	}
+}
+
+func TestSurrogatePairs(t *testing.T) {
+	tests := []struct {
+		src, text, err string
+	}{
+		{`"\ud83d\ude00"`, "\U0001F600", ""},
+		{`"a\ud83d\ude00b"`, "a\U0001F600b", ""},
+		{`'\ud83d\ude00'`, "\U0001F600", ""},
+		{`'''\ud83d\ude00'''`, "\U0001F600", ""},
+		{`"\ud83d"`, "", `1:1: Invalid token: unpaired high surrogate \ud83d`},
+		{`"\ud83dx"`, "", `1:1: Invalid token: unpaired high surrogate \ud83d`},
+		{`"\ud83dA"`, "", `1:1: Invalid token: unpaired high surrogate \ud83d`},
+		{`"\ud83d\u0041"`, "", `1:1: Invalid token: unpaired high surrogate \ud83d`},
+		{`"\ude00"`, "", `1:1: Invalid token: unpaired low surrogate \ude00`},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		if got := errorString(err); got != test.err {
+			t.Errorf("Parse(%s): got error %q, want %q", test.src, got, test.err)
+		} else if err == nil && v.Text != test.text {
+			t.Errorf("Parse(%s): got %q, want %q", test.src, v.Text, test.text)
+		}
+	}
+}
+
+func TestLongStrings(t *testing.T) {
+	tests := []struct {
+		src, text, err string
+	}{
+		{"'''abc'''", "abc", ""},
+		{"'''a\nb'''", "a\nb", ""},
+		{`'''a\tb\'''c'''`, "a\tb'''c", ""},
+		{`'''it's "quoted"'''`, `it's "quoted"`, ""},
+		{"''''''", "", ""},
+		{"'''abc", "", "1:1: Invalid token: unterminated long string, expected '''"},
+		{"'''abc''", "", "1:1: Invalid token: unterminated long string, expected '''"},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		if got := errorString(err); got != test.err {
+			t.Errorf("Parse(%q): got error %q, want %q", test.src, got, test.err)
+		} else if err == nil && (v.Type != StringType || v.Text != test.text) {
+			t.Errorf("Parse(%q): got %s %q, want string %q", test.src, v.Type, v.Text, test.text)
+		}
+	}
+}
+
+func TestBlockComments(t *testing.T) {
+	tests := []struct {
+		src, want, err string
+	}{
+		{"/* c */ 1", "1", ""},
+		{"1 /* c */", "1", ""},
+		{"/**/ 5", "5", ""},
+		{"[1, /* two */ 2]", "[1, 2]", ""},
+		{"/* a * b / c */ x", "x", ""},
+		{"/* multi\nline */ {a: /* in */ 1}", "{a: 1}", ""},
+		{"/* unterminated", "", "1:1: Invalid token: unterminated block comment"},
+		{"[1, /* unterminated", "", "1:5: Invalid token: unterminated block comment"},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		if got := errorString(err); got != test.err {
+			t.Errorf("Parse(%q): got error %q, want %q", test.src, got, test.err)
+		} else if err == nil && v.String() != test.want {
+			t.Errorf("Parse(%q): got %s, want %s", test.src, v, test.want)
+		}
+	}
+}
+
+func TestUnicodeEscapes(t *testing.T) {
+	tests := []struct {
+		src, text, err string
+	}{
+		{`"\u0041"`, "A", ""},
+		{`"\u00e9t\u00E9"`, "été", ""},
+		{`"\U00000041"`, "A", ""},
+		{`"\U0001f600"`, "\U0001F600", ""},
+		{`'\u00e9'`, "é", ""},
+		{`"\x41"`, "A", ""},
+		{`"\u12"`, "", `1:1: Invalid token: invalid escape \u12": expected 4 hex digits`},
+		{`"\U1234"`, "", `1:1: Invalid token: invalid escape \U1234": expected 8 hex digits`},
+		{`"\U0011FFFF"`, "", `1:1: Invalid token: invalid escape \U0011ffff: not a valid code point`},
+		{`"\q"`, "", `1:1: Invalid token: invalid escape \q`},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		if got := errorString(err); got != test.err {
+			t.Errorf("Parse(%s): got error %q, want %q", test.src, got, test.err)
+		} else if err == nil && v.Text != test.text {
+			t.Errorf("Parse(%s): got %q, want %q", test.src, v.Text, test.text)
+		}
+	}
+}
+
+func TestDollarSymbols(t *testing.T) {
+	tests := []struct {
+		src  string
+		text string
+		sid  int
+		isID bool
+	}{
+		{"$123", "$123", 123, true},
+		{"$0", "$0", 0, true},
+		{"$ion", "$ion", 0, false},
+		{"$1abc", "$1abc", 0, false},
+		{"$_x", "$_x", 0, false},
+		{"$", "$", 0, false},
+		{"'$123'", "$123", 0, false},
+		{"$ion_symbol_table", "$ion_symbol_table", 0, false},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if v.Type != SymbolType || v.Text != test.text {
+			t.Errorf("Parse(%s): got %s %q, want symbol %q", test.src, v.Type, v.Text, test.text)
+		}
+		if sid, ok := v.SID(); sid != test.sid || ok != test.isID {
+			t.Errorf("Parse(%s).SID(): got %d, %v, want %d, %v", test.src, sid, ok, test.sid, test.isID)
+		}
+		if got := v.String(); got != test.src {
+			t.Errorf("Parse(%s).String(): got %s", test.src, got)
+		}
+	}
+	for _, v := range []Value{{Type: SymbolType, SymbolID: true}, {Type: SymbolType, Text: "$", SymbolID: true}, {Type: SymbolType, Text: "10", SymbolID: true}} {
+		if sid, ok := v.SID(); ok {
+			t.Errorf("SID of a symbol with text %q: got %d, want none", v.Text, sid)
+		}
+	}
+}
+
+func TestScannerReset(t *testing.T) {
+	s := NewScanner(strings.NewReader("[1,\n  abc"))
+	for tok, _ := s.Scan(); tok != EOF; tok, _ = s.Scan() {
+	}
+	s.Reset(strings.NewReader("foo 2"))
+	tok, lit := s.Scan()
+	if line, col := s.Position(); tok != SYMBOL || lit != "foo" || line != 1 || col != 1 {
+		t.Errorf("after Reset: got %v %q at %d:%d, want SYMBOL \"foo\" at 1:1", tok, lit, line, col)
+	}
+	s.Scan() //the space
+	tok, lit = s.Scan()
+	if line, col := s.Position(); tok != NUMBER || lit != "2" || line != 1 || col != 5 {
+		t.Errorf("after Reset: got %v %q at %d:%d, want NUMBER \"2\" at 1:5", tok, lit, line, col)
+	}
+}
+
+func TestParserReset(t *testing.T) {
+	p := NewParser("first", strings.NewReader("[1, 2] {a:"))
+	p.Strict = true
+	if _, err := p.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Next(); err == nil {
+		t.Fatal("expected an error for the unterminated struct")
+	}
+	p.Reset("second", strings.NewReader("{b: 3}\n[4 5]"))
+	v, err := p.Next()
+	if err != nil || v.String() != "{b: 3}" {
+		t.Errorf("after Reset: got %v, %v, want {b: 3}", v, err)
+	}
+	_, err = p.Next()
+	if got, want := errorString(err), "second:2:4: Missing ',' between list elements"; got != want {
+		t.Errorf("after Reset: got error %q, want %q (keeping Strict)", got, want)
+	}
+}
+
+func BenchmarkParserReset(b *testing.B) {
+	src := `{id: 1, name: "x", tags: [a, b]}`
+	r := strings.NewReader(src)
+	p := NewParser("", r)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(src)
+		p.Reset("", r)
+		if _, err := p.Next(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewParser(b *testing.B) {
+	src := `{id: 1, name: "x", tags: [a, b]}`
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewParser("", strings.NewReader(src)).Next(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCRLF(t *testing.T) {
+	doc := "// saved on Windows\r\n{\r\n  name: \"x\",\r\n  list: [1,\r\n    2]\r\n}\r\n"
+	if got := mustParse(t, doc).String(); got != `{name: "x", list: [1, 2]}` {
+		t.Errorf("Parse of a CRLF document: got %s", got)
+	}
+	tests := []struct {
+		src, err string
+	}{
+		{"{\r\n  a: 1,\r\n  b: ]\r\n}", "3:6: unexpected ']' in struct opened at 1:1"},
+		{"[1,\r2,\r\r  )]", "4:3: unexpected ')' in list opened at 1:1"},
+		{"[1,\v\f\r\n\t2 ]", ""},
+	}
+	for _, test := range tests {
+		if got := parseError(test.src); got != test.err {
+			t.Errorf("Parse(%q): got error %q, want %q", test.src, got, test.err)
+		}
+	}
+}
+
+func TestUnterminatedLiterals(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{`"abc`, `1:1: Invalid token: unterminated string, expected '"'`},
+		{`["abc`, `1:2: Invalid token: unterminated string, expected '"'`},
+		{`"ab\`, "1:1: Invalid token: unterminated escape sequence"},
+		{`'abc`, `1:1: Invalid token: unterminated symbol, expected "'"`},
+		{`{a: 'b`, `1:5: Invalid token: unterminated symbol, expected "'"`},
+		{`'''abc`, "1:1: Invalid token: unterminated long string, expected '''"},
+		{`{{ "ab`, "1:1: Invalid token: unterminated clob string"},
+	}
+	for _, test := range tests {
+		if got := parseError(test.src); got != test.err {
+			t.Errorf("Parse(%s): got error %q, want %q", test.src, got, test.err)
+		}
+	}
+	_, err := ParseAll(strings.NewReader(`"ok" "x`))
+	if got, want := errorString(err), `1:6: Invalid token: unterminated string, expected '"'`; got != want {
+		t.Errorf("ParseAll of a stream ending in an unterminated string: got error %q, want %q", got, want)
+	}
+}
+
+func TestByteOrderMark(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"\uFEFF{a: 1}", "{a: 1}"},
+		{"\uFEFF// exported\r\n[1, 2]", "[1, 2]"},
+		{"\uFEFF", "<nil>"},
+		{"\uFEFF[1, }", "1:5: expected ']' to close list opened at 1:1, found '}'"},
+		{"[1, \uFEFF2]", "1:5: Invalid token: byte-order mark after the start of the input"},
+		{"\uFEFF\uFEFF1", "1:1: Invalid token: byte-order mark after the start of the input"},
+		{`"\uFEFF"`, `"\ufeff"`},
+	}
+	for _, test := range tests {
+		v, err := Parse(strings.NewReader(test.src))
+		got := errorString(err)
+		if err == nil {
+			got = fmt.Sprint(v)
+		}
+		if got != test.want {
+			t.Errorf("Parse(%q): got %s, want %s", test.src, got, test.want)
+		}
+	}
+	p := NewParser("", strings.NewReader("\uFEFF1"))
+	p.Next()
+	p.Reset("", strings.NewReader("\uFEFF2"))
+	if v, err := p.Next(); err != nil || v.String() != "2" {
+		t.Errorf("after Reset: got %v, %v, want 2", v, err)
+	}
+}
@@ -0,0 +1,97 @@
+package ion
+
+// InferSchema examines a set of example values and describes their shape as an Ion struct. Each
+// shape lists the types observed at that position; struct shapes add a "fields" struct giving the
+// shape of every field seen, marked optional if some examples lack it, and list and sexp shapes
+// add an "element" shape summarizing all of their elements. For example, the records
+// {name: "a", tags: [x]} and {name: "b"} infer
+//
+//	{types: [struct], fields: {name: {types: [string], optional: false},
+//	  tags: {types: [list], optional: true, element: {types: [symbol]}}}}
+func InferSchema(values []*Value) Value {
+	var s shape
+	for _, v := range values {
+		if v != nil {
+			s.observe(*v)
+		}
+	}
+	return s.value()
+}
+
+// shape accumulates what has been observed of the values at one position in a set of documents.
+type shape struct {
+	types   []Type
+	structs int //number of structs observed
+	names   []string
+	fields  map[string]*shape
+	counts  map[string]int //number of structs containing each field
+	element *shape
+}
+
+func (s *shape) observe(v Value) {
+	found := false
+	for _, t := range s.types {
+		if t == v.Type {
+			found = true
+			break
+		}
+	}
+	if !found {
+		s.types = append(s.types, v.Type)
+	}
+	switch v.Type {
+	case StructType:
+		if s.fields == nil {
+			s.fields = make(map[string]*shape)
+			s.counts = make(map[string]int)
+		}
+		s.structs++
+		seen := make(map[string]bool)
+		for _, field := range v.Struct {
+			fs, ok := s.fields[field.Name]
+			if !ok {
+				fs = &shape{}
+				s.fields[field.Name] = fs
+				s.names = append(s.names, field.Name)
+			}
+			fs.observe(field.Value)
+			if !seen[field.Name] {
+				seen[field.Name] = true
+				s.counts[field.Name]++
+			}
+		}
+	case ListType, SexpType:
+		if s.element == nil {
+			s.element = &shape{}
+		}
+		for _, item := range v.Sequence {
+			s.element.observe(item)
+		}
+	}
+}
+
+// value describes the shape, adding any extra fields after its types.
+func (s *shape) value(extra ...Field) Value {
+	types := make([]Value, 0, len(s.types))
+	for _, t := range s.types {
+		types = append(types, Value{Type: SymbolType, Text: t.String()})
+	}
+	desc := []Field{{Name: "types", Value: Value{Type: ListType, Sequence: types}}}
+	desc = append(desc, extra...)
+	if s.fields != nil {
+		fields := make([]Field, 0, len(s.names))
+		for _, name := range s.names {
+			optional := Value{Type: BoolType}
+			if s.counts[name] < s.structs {
+				optional.Int = 1
+			}
+			fv := s.fields[name].value(Field{Name: "optional", Value: optional})
+			fields = append(fields, Field{Name: name, Value: fv})
+		}
+		desc = append(desc, Field{Name: "fields", Value: Value{Type: StructType, Struct: fields}})
+	}
+	if s.element != nil && len(s.element.types) > 0 {
+		desc = append(desc, Field{Name: "element", Value: s.element.value()})
+	}
+	return Value{Type: StructType, Struct: desc}
+}
@@ -0,0 +1,34 @@
+package ion
+
+import (
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	tests := []struct {
+		records string
+		want    string
+	}{
+		{`{name: "a", tags: [x]} {name: "b"}`,
+			`{types: [struct], fields: {name: {types: [string], optional: false},
+			  tags: {types: [list], optional: true, element: {types: [symbol]}}}}`},
+		{`{id: 1, v: 1.5e0} {id: 2, v: "n/a", extra: true} {id: 3, v: [1, "x", {k: 1}]}`,
+			`{types: [struct], fields: {id: {types: [int], optional: false},
+			  v: {types: [float, string, list], optional: false,
+			    element: {types: [int, string, struct], fields: {k: {types: [int], optional: false}}}},
+			  extra: {types: [bool], optional: true}}}`},
+		{`1 "two" []`, `{types: [int, string, list]}`},
+		{``, `{types: []}`},
+	}
+	for _, test := range tests {
+		var records []*Value
+		values := mustParse(t, "("+test.records+")").Sequence
+		for i := range values {
+			records = append(records, &values[i])
+		}
+		got := InferSchema(records).String()
+		if want := mustParse(t, test.want).String(); got != want {
+			t.Errorf("InferSchema(%s):\ngot  %s\nwant %s", test.records, got, want)
+		}
+	}
+}
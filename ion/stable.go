@@ -0,0 +1,171 @@
+package ion
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// StableString returns the value as Ion text in a form that is guaranteed not to change from one
+// version of this package to the next, for golden tests to compare against. Unlike String, whose
+// layout may be improved over time, it follows these fixed rules:
+//
+//   - everything is on one line, with ", " between the items of lists and the fields of structs,
+//     ": " after field names, and a single space between the items of sexps
+//   - struct fields are sorted by name, and fields with the same name by their own stable text
+//   - annotations, field names, and symbols are always in single quotes, except for symbol IDs
+//     such as $10, which are written as they are
+//   - ints are in base 10, whatever base they were written in
+//   - decimals are written as their coefficient and exponent, as in 125d-2 for 1.25
+//   - floats are written in exponent form with the shortest digits that read back exactly, as in
+//     1.5e0, or as nan, +inf, or -inf
+//   - timestamps are written at the precision they have
+//   - in strings, symbols, and clobs, the quote character and backslash are escaped with a
+//     backslash, as are newline, tab, and carriage return (\n, \t, \r); other control characters
+//     are written as \xHH, and in clobs, so are bytes above 0x7e
+//   - in strings and symbols, each byte that is not valid UTF-8 is written as U+FFFD, the
+//     replacement character, since Ion text cannot hold such bytes
+//   - blobs are written in standard base64 with padding
+//   - typed nulls are written with their type, as in null.int
+func (v Value) StableString() string {
+	var buf bytes.Buffer
+	writeStable(&buf, v)
+	return buf.String()
+}
+
+func writeStable(buf *bytes.Buffer, v Value) {
+	for _, anno := range v.Annotations {
+		writeStableQuoted(buf, anno, '\'', false)
+		buf.WriteString("::")
+	}
+	if v.Null && v.Type != NullType {
+		buf.WriteString("null." + v.Type.String())
+		return
+	}
+	switch v.Type {
+	case NullType:
+		buf.WriteString("null")
+	case BoolType:
+		buf.WriteString(strconv.FormatBool(v.Int != 0))
+	case IntType:
+		buf.WriteString(v.bigInt().String())
+	case FloatType:
+		buf.WriteString(stableFloat(v.Float))
+	case DecimalType:
+		if v.Decimal.NegativeZero {
+			buf.WriteByte('-')
+		}
+		fmt.Fprintf(buf, "%sd%d", v.Decimal.Coefficient, v.Decimal.Exponent)
+	case TimestampType:
+		buf.WriteString(formatTimestamp(v.Time, v.Precision))
+	case StringType:
+		writeStableQuoted(buf, v.Text, '"', false)
+	case SymbolType:
+		if v.SymbolID {
+			buf.WriteString(v.Text)
+		} else {
+			writeStableQuoted(buf, v.Text, '\'', false)
+		}
+	case BlobType:
+		buf.WriteString("{{" + base64.StdEncoding.EncodeToString(v.Bytes) + "}}")
+	case ClobType:
+		buf.WriteString("{{")
+		writeStableQuoted(buf, string(v.Bytes), '"', true)
+		buf.WriteString("}}")
+	case ListType, SexpType:
+		openCh, sep, closeCh := "[", ", ", "]"
+		if v.Type == SexpType {
+			openCh, sep, closeCh = "(", " ", ")"
+		}
+		buf.WriteString(openCh)
+		for i, item := range v.Sequence {
+			if i > 0 {
+				buf.WriteString(sep)
+			}
+			writeStable(buf, item)
+		}
+		buf.WriteString(closeCh)
+	case StructType:
+		type entry struct {
+			name, text string
+		}
+		entries := make([]entry, len(v.Struct))
+		for i, field := range v.Struct {
+			var name bytes.Buffer
+			for _, anno := range field.NameAnnotations {
+				writeStableQuoted(&name, anno, '\'', false)
+				name.WriteString("::")
+			}
+			writeStableQuoted(&name, field.Name, '\'', false)
+			entries[i] = entry{name: field.Name, text: name.String() + ": " + field.Value.StableString()}
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].name != entries[j].name {
+				return entries[i].name < entries[j].name
+			}
+			return entries[i].text < entries[j].text
+		})
+		buf.WriteByte('{')
+		for i, e := range entries {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(e.text)
+		}
+		buf.WriteByte('}')
+	}
+}
+
+// stableFloat writes a float in exponent form, as 1.5e0 rather than Go's 1.5e+00.
+func stableFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "+inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	i := strings.IndexByte(s, 'e')
+	exp, _ := strconv.Atoi(s[i+1:])
+	return s[:i+1] + strconv.Itoa(exp)
+}
+
+// writeStableQuoted writes text between quote characters, escaped by the rules of StableString.
+// If ascii is set, as for a clob, every byte above 0x7e is escaped as \xHH. Otherwise bytes that
+// are not valid UTF-8 are replaced by U+FFFD, since \xHH in a string or symbol means the code
+// point U+00HH rather than the byte.
+func writeStableQuoted(buf *bytes.Buffer, text string, quote byte, ascii bool) {
+	buf.WriteByte(quote)
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if ascii && text[i] > 0x7e {
+			r, size = utf8.RuneError, 1
+		}
+		switch {
+		case r == rune(quote) || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n':
+			buf.WriteString("\\n")
+		case r == '\t':
+			buf.WriteString("\\t")
+		case r == '\r':
+			buf.WriteString("\\r")
+		case r == utf8.RuneError && size == 1 && !ascii:
+			buf.WriteRune(utf8.RuneError)
+		case r < 0x20 || r == 0x7f || r == utf8.RuneError && size == 1:
+			fmt.Fprintf(buf, "\\x%02x", text[i])
+		default:
+			buf.WriteString(text[i : i+size])
+		}
+		i += size
+	}
+	buf.WriteByte(quote)
+}
@@ -0,0 +1,50 @@
+package ion
+
+import (
+	"testing"
+)
+
+func TestStableString(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{
+			`doc::{zeta: [1, 0x1F, -2.50, 1.5e0, nan, -inf, 2023-01-02T03:04Z], alpha: "tab\there é",
+			'b c': (+ x), 'null': null.int, blob: {{aGk=}}, clob: {{"hi"}}, sym: 'null', dup: 2, dup: 1,
+			big: 123456789012345678901234567890}`,
+			`'doc'::{'alpha': "tab\there é", 'b c': ('+' 'x'), 'big': 123456789012345678901234567890, ` +
+				`'blob': {{aGk=}}, 'clob': {{"hi"}}, 'dup': 1, 'dup': 2, 'null': null.int, 'sym': 'null', ` +
+				`'zeta': [1, 31, -250d-2, 1.5e0, nan, -inf, 2023-01-02T03:04Z]}`,
+		},
+		{`[$10, "a\"b\\c\nd\x01", {{"\xff\x01\""}}, 1.25, 0.0, -0.0, 1e0, 100e0, 1.5d3]`,
+			`[$10, "a\"b\\c\nd\x01", {{"\xff\x01\""}}, 125d-2, 0d-1, -0d-1, 1e0, 1e2, 15d2]`},
+		{"{}", "{}"},
+		{"()", "()"},
+		{"null", "null"},
+	}
+	for _, test := range tests {
+		if got := mustParse(t, test.src).StableString(); got != test.want {
+			t.Errorf("StableString(%s):\ngot  %s\nwant %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestStableStringInvalidUTF8(t *testing.T) {
+	tests := []struct {
+		v    Value
+		want string
+	}{
+		{Value{Type: StringType, Text: "a\xffb"}, "\"a\xef\xbf\xbdb\""},
+		{Value{Type: SymbolType, Text: "\xc3"}, "'\xef\xbf\xbd'"},
+		{Value{Type: ClobType, Bytes: []byte("a\xffb")}, `{{"a\xffb"}}`},
+	}
+	for _, test := range tests {
+		got := test.v.StableString()
+		if got != test.want {
+			t.Errorf("StableString(%q): got %q, want %q", test.v.Text, got, test.want)
+		}
+		if back := mustParse(t, got); back.StableString() != got {
+			t.Errorf("StableString(%q) = %q does not read back the same", test.v.Text, got)
+		}
+	}
+}
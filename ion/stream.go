@@ -0,0 +1,121 @@
+package ion
+
+import (
+	"io"
+)
+
+// Decoder reads a stream of top-level values into Go values, as encoding/json's Decoder does for
+// JSON.
+type Decoder struct {
+	reader *Reader
+}
+
+// NewDecoder returns a decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{reader: NewReader(r)}
+}
+
+// Decode reads the next top-level value and stores it into the Go value that v points to, as
+// Value.Unmarshal does, or into the Value itself if v is a *Value. It returns io.EOF when the
+// stream is exhausted.
+func (d *Decoder) Decode(v interface{}) error {
+	val, err := d.reader.Next()
+	if err != nil {
+		return err
+	}
+	if target, ok := v.(*Value); ok {
+		*target = *val
+		return nil
+	}
+	return val.Unmarshal(v)
+}
+
+// Parser returns the parser the decoder reads with, so its options can be set before the first
+// call to Decode.
+func (d *Decoder) Parser() *Parser {
+	return d.reader.Parser()
+}
+
+// Encoder writes a stream of top-level values to an io.Writer as Ion text, converting Go values
+// with Marshal, as encoding/json's Encoder does for JSON.
+type Encoder struct {
+	w       io.Writer
+	opts    WriteOptions
+	newline bool
+	open    bool //whether the last value written has not been followed by a newline
+}
+
+// NewEncoder returns an encoder writing to w. Values are written on a single line, separated by
+// spaces, unless SetIndent or SetNewlineDelimited say otherwise.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SetIndent makes the encoder write each value in BlockStyle, with indent added at each level of
+// nesting, and followed by a newline. An empty indent restores the single-line form.
+func (e *Encoder) SetIndent(indent string) {
+	e.opts.Indent = indent
+	e.opts.Style = CompactStyle
+	if indent != "" {
+		e.opts.Style = BlockStyle
+	}
+}
+
+// SetQuoteSymbols makes the encoder write every symbol, annotation, and field name in single quotes,
+// rather than only those that need them.
+func (e *Encoder) SetQuoteSymbols(quote bool) {
+	e.opts.QuoteSymbols = quote
+}
+
+// SetNewlineDelimited makes the encoder write each value on a line of its own, followed by a
+// newline, as in newline-delimited JSON. The indent, if any, is then ignored.
+func (e *Encoder) SetNewlineDelimited(newline bool) {
+	e.newline = newline
+}
+
+// Encode writes v, which may be a Value or *Value, or else any Go value Marshal accepts. A nil
+// *Value is written as null, as Marshal writes a nil pointer.
+func (e *Encoder) Encode(v interface{}) error {
+	var val Value
+	switch v := v.(type) {
+	case Value:
+		val = v
+	case *Value:
+		if v == nil {
+			val = Null()
+		} else {
+			val = *v
+		}
+	default:
+		m, err := Marshal(v)
+		if err != nil {
+			return err
+		}
+		val = *m
+	}
+	opts := e.opts
+	if e.newline {
+		opts.Style = CompactStyle
+	}
+	separated := e.newline || opts.Style != CompactStyle
+	p := printer{w: e.w, opts: opts}
+	if e.open {
+		if separated {
+			p.buf.WriteByte('\n')
+		} else {
+			p.buf.WriteByte(' ')
+		}
+	}
+	e.open = !separated
+	if opts.Style == CompactStyle {
+		p.print(val)
+	} else {
+		p.comments(val, 0)
+		p.block(val, 0)
+	}
+	if separated {
+		p.buf.WriteByte('\n')
+	}
+	p.flush()
+	return p.err
+}
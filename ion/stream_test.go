@@ -0,0 +1,120 @@
+package ion
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{id: 1, city: "Oslo"} {id: 2} // done`))
+	var got []marshalAddress
+	for {
+		var rec marshalAddress
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, rec)
+	}
+	if want := []marshalAddress{{City: "Oslo"}, {}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	dec = NewDecoder(strings.NewReader("a::[1, 2] 3"))
+	var v Value
+	if err := dec.Decode(&v); err != nil || v.String() != "a::[1, 2]" {
+		t.Errorf("Decode into a *Value: got %v, %v", v, err)
+	}
+	var n int
+	if err := dec.Decode(&n); err != nil || n != 3 {
+		t.Errorf("Decode into an int: got %d, %v", n, err)
+	}
+	if err := dec.Decode(&n); err != io.EOF {
+		t.Errorf("Decode at the end: got %v, want io.EOF", err)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{`"x"`, "Cannot unmarshal string into Go value of type int"},
+		{"[1, ", "1:5: expected ']' to close list opened at 1:1, found EOF"},
+	}
+	for _, test := range tests {
+		var n int
+		if got := errorString(NewDecoder(strings.NewReader(test.src)).Decode(&n)); got != test.err {
+			t.Errorf("Decode(%s): got error %q, want %q", test.src, got, test.err)
+		}
+	}
+	dec := NewDecoder(strings.NewReader("[1 2]"))
+	dec.Parser().Strict = true
+	var ns []int
+	if got, want := errorString(dec.Decode(&ns)), "1:4: Missing ',' between list elements"; got != want {
+		t.Errorf("Decode with a strict parser: got error %q, want %q", got, want)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	values := []interface{}{
+		mustParse(t, `{a: [1, "x"], 'b c': sym}`),
+		marshalAddress{City: "Oslo"},
+		*mustParse(t, "ann::3"),
+	}
+	tests := []struct {
+		name  string
+		setup func(e *Encoder)
+		want  string
+	}{
+		{"default", func(e *Encoder) {}, `{a: [1, "x"], 'b c': sym} {city: "Oslo"} ann::3`},
+		{"newline delimited", func(e *Encoder) { e.SetNewlineDelimited(true) }, "{a: [1, \"x\"], 'b c': sym}\n{city: \"Oslo\"}\nann::3\n"},
+		{"quoted symbols", func(e *Encoder) { e.SetQuoteSymbols(true) }, `{'a': [1, "x"], 'b c': 'sym'} {'city': "Oslo"} 'ann'::3`},
+		{"indented", func(e *Encoder) { e.SetIndent("  ") }, "{\n  a: [\n    1,\n    \"x\"\n  ],\n  'b c': sym\n}\n{\n  city: \"Oslo\"\n}\nann::3\n"},
+		{"indent reset", func(e *Encoder) { e.SetIndent("  "); e.SetIndent("") }, `{a: [1, "x"], 'b c': sym} {city: "Oslo"} ann::3`},
+		{"newline delimited ignores indent", func(e *Encoder) { e.SetIndent("  "); e.SetNewlineDelimited(true) }, "{a: [1, \"x\"], 'b c': sym}\n{city: \"Oslo\"}\nann::3\n"},
+	}
+	for _, test := range tests {
+		var buf strings.Builder
+		e := NewEncoder(&buf)
+		test.setup(e)
+		for _, v := range values {
+			if err := e.Encode(v); err != nil {
+				t.Fatalf("%s: Encode(%v): %v", test.name, v, err)
+			}
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", test.name, got, test.want)
+		}
+	}
+	var buf strings.Builder
+	var nilValue *Value
+	if err := NewEncoder(&buf).Encode(nilValue); err != nil || buf.String() != "null" {
+		t.Errorf("Encode(nil *Value): got %q, %v, want null", buf.String(), err)
+	}
+	if err := NewEncoder(io.Discard).Encode(make(chan int)); err == nil {
+		t.Error("Encode(chan int): expected an error")
+	}
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	var buf strings.Builder
+	e := NewEncoder(&buf)
+	e.SetNewlineDelimited(true)
+	for i := 0; i < 3; i++ {
+		if err := e.Encode(marshalBase{ID: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewDecoder(strings.NewReader(buf.String()))
+	for i := 0; i < 3; i++ {
+		var b marshalBase
+		if err := dec.Decode(&b); err != nil || b.ID != int64(i) {
+			t.Errorf("value %d: got %+v, %v", i, b, err)
+		}
+	}
+}
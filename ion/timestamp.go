@@ -0,0 +1,200 @@
+package ion
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// TimestampPrecision is the finest unit present in a timestamp's text form, which Ion preserves:
+// 2023T and 2023-01-01T00:00Z are different values. Precisions beyond SecondPrecision count digits
+// of fractional seconds, so a timestamp written with milliseconds has precision SecondPrecision+3.
+type TimestampPrecision int
+
+const (
+	YearPrecision TimestampPrecision = iota + 1
+	MonthPrecision
+	DayPrecision
+	MinutePrecision
+	SecondPrecision
+)
+
+// unknownOffset is the location of timestamps written with the -00:00 offset, which Ion uses for
+// a UTC time whose local offset is unknown.
+var unknownOffset = time.FixedZone("-00:00", 0)
+
+// parseTimestamp parses the text of an Ion timestamp.
+func parseTimestamp(lit string) (*Value, error) {
+	bad := fmt.Errorf("Invalid timestamp: %q", lit)
+	s := lit
+	num := func(n int) (int, bool) {
+		if len(s) < n {
+			return 0, false
+		}
+		for i := 0; i < n; i++ {
+			if !isDigit(rune(s[i])) {
+				return 0, false
+			}
+		}
+		i, _ := strconv.Atoi(s[:n])
+		s = s[n:]
+		return i, true
+	}
+	next := func(c byte) bool {
+		if len(s) > 0 && s[0] == c {
+			s = s[1:]
+			return true
+		}
+		return false
+	}
+	month, day, hour, minute, second, nsec := 1, 1, 0, 0, 0, 0
+	precision := YearPrecision
+	loc := time.UTC
+	year, ok := num(4)
+	if !ok {
+		return nil, bad
+	}
+	if next('-') {
+		if month, ok = num(2); !ok {
+			return nil, bad
+		}
+		precision = MonthPrecision
+		if next('-') {
+			if day, ok = num(2); !ok {
+				return nil, bad
+			}
+			precision = DayPrecision
+		}
+	}
+	if !next('T') {
+		if precision != DayPrecision {
+			return nil, bad
+		}
+	} else if s != "" {
+		if precision != DayPrecision {
+			return nil, bad
+		}
+		if hour, ok = num(2); !ok || !next(':') {
+			return nil, bad
+		}
+		if minute, ok = num(2); !ok {
+			return nil, bad
+		}
+		precision = MinutePrecision
+		if next(':') {
+			if second, ok = num(2); !ok {
+				return nil, bad
+			}
+			precision = SecondPrecision
+			if next('.') {
+				digits := 0
+				for len(s) > 0 && isDigit(rune(s[0])) {
+					if digits < 9 {
+						nsec = nsec*10 + int(s[0]-'0')
+					}
+					digits++
+					s = s[1:]
+				}
+				if digits == 0 {
+					return nil, bad
+				}
+				for i := digits; i < 9; i++ {
+					nsec *= 10
+				}
+				precision += TimestampPrecision(digits)
+			}
+		}
+		if !next('Z') {
+			sign := 1
+			if next('-') {
+				sign = -1
+			} else if !next('+') {
+				return nil, bad
+			}
+			offHour, ok := num(2)
+			if !ok || !next(':') || offHour > 23 {
+				return nil, bad
+			}
+			offMinute, ok := num(2)
+			if !ok || offMinute > 59 {
+				return nil, bad
+			}
+			offset := sign * (offHour*60 + offMinute) * 60
+			if offset == 0 && sign < 0 {
+				loc = unknownOffset
+			} else if offset != 0 {
+				loc = time.FixedZone("", offset)
+			}
+		}
+	}
+	if s != "" || month < 1 || month > 12 || hour > 23 || minute > 59 || second > 59 {
+		return nil, bad
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, second, nsec, loc)
+	if t.Day() != day {
+		return nil, bad
+	}
+	return &Value{Type: TimestampType, Time: t, Precision: precision}, nil
+}
+
+// formatTimestamp renders a timestamp in Ion text, to the given precision.
+func formatTimestamp(t time.Time, precision TimestampPrecision) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%04d", t.Year())
+	if precision == YearPrecision {
+		buf.WriteRune('T')
+		return buf.String()
+	}
+	fmt.Fprintf(&buf, "-%02d", int(t.Month()))
+	if precision == MonthPrecision {
+		buf.WriteRune('T')
+		return buf.String()
+	}
+	fmt.Fprintf(&buf, "-%02d", t.Day())
+	if precision <= DayPrecision {
+		return buf.String()
+	}
+	fmt.Fprintf(&buf, "T%02d:%02d", t.Hour(), t.Minute())
+	if precision >= SecondPrecision {
+		fmt.Fprintf(&buf, ":%02d", t.Second())
+		if digits := int(precision - SecondPrecision); digits > 0 {
+			frac := fmt.Sprintf("%09d", t.Nanosecond())
+			for len(frac) < digits {
+				frac += "0"
+			}
+			buf.WriteRune('.')
+			buf.WriteString(frac[:digits])
+		}
+	}
+	_, offset := t.Zone()
+	switch {
+	case t.Location() == unknownOffset:
+		buf.WriteString("-00:00")
+	case offset == 0:
+		buf.WriteRune('Z')
+	default:
+		sign := '+'
+		if offset < 0 {
+			sign = '-'
+			offset = -offset
+		}
+		fmt.Fprintf(&buf, "%c%02d:%02d", sign, offset/3600, offset/60%60)
+	}
+	return buf.String()
+}
+
+// UnixMillis returns the time of a non-null timestamp as milliseconds since the Unix epoch, and
+// false for anything else.
+func (v *Value) UnixMillis() (int64, bool) {
+	if v.Type != TimestampType || v.Null {
+		return 0, false
+	}
+	return v.Time.UnixMilli(), true
+}
+
+// TimestampFromUnixMillis returns a UTC timestamp, with millisecond precision, for a time given in
+// milliseconds since the Unix epoch.
+func TimestampFromUnixMillis(ms int64) Value {
+	return Value{Type: TimestampType, Time: time.UnixMilli(ms).UTC(), Precision: SecondPrecision + 3}
+}
@@ -0,0 +1,78 @@
+package ion
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimestamps(t *testing.T) {
+	tests := []struct {
+		src       string
+		want      string
+		precision TimestampPrecision
+		time      time.Time
+	}{
+		{"2023T", "2023T", YearPrecision, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2023-01T", "2023-01T", MonthPrecision, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2023-01-02", "2023-01-02", DayPrecision, time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"2023-01-02T", "2023-01-02", DayPrecision, time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"2007-02-23T12:14Z", "2007-02-23T12:14Z", MinutePrecision, time.Date(2007, 2, 23, 12, 14, 0, 0, time.UTC)},
+		{"2023-01-02T03:04:05Z", "2023-01-02T03:04:05Z", SecondPrecision, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"2023-01-02T03:04:05+00:00", "2023-01-02T03:04:05Z", SecondPrecision, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"2007-02-23T12:14:33.079-08:00", "2007-02-23T12:14:33.079-08:00", SecondPrecision + 3,
+			time.Date(2007, 2, 23, 20, 14, 33, 79000000, time.UTC)},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if v.Type != TimestampType {
+			t.Errorf("Parse(%q): got type %d, want a timestamp", test.src, v.Type)
+			continue
+		}
+		if got := v.String(); got != test.want {
+			t.Errorf("Parse(%q).String(): got %s, want %s", test.src, got, test.want)
+		}
+		if v.Precision != test.precision {
+			t.Errorf("Parse(%q): got precision %d, want %d", test.src, v.Precision, test.precision)
+		}
+		if !v.Time.Equal(test.time) {
+			t.Errorf("Parse(%q): got time %v, want %v", test.src, v.Time, test.time)
+		}
+	}
+}
+
+func TestInvalidTimestamps(t *testing.T) {
+	for _, src := range []string{"2023-13-02", "2023-02-30", "2023-00-01", "2023-01-02T25:00Z"} {
+		if got, want := parseError(src), `1:1: Invalid timestamp: "`+src+`"`; got != want {
+			t.Errorf("Parse(%q): got error %q, want %q", src, got, want)
+		}
+	}
+}
+
+func TestUnixMillis(t *testing.T) {
+	tests := []struct {
+		src  string
+		ms   int64
+		back string
+	}{
+		{"1970-01-01T00:00:00.000Z", 0, "1970-01-01T00:00:00.000Z"},
+		{"2023-01-02T03:04:05.678Z", 1672628645678, "2023-01-02T03:04:05.678Z"},
+		{"2023-01-02T04:04:05.678+01:00", 1672628645678, "2023-01-02T03:04:05.678Z"},
+		{"2023-01-02T03:04:05Z", 1672628645000, "2023-01-02T03:04:05.000Z"},
+		{"1969-12-31T23:59:59.999Z", -1, "1969-12-31T23:59:59.999Z"},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		ms, ok := v.UnixMillis()
+		if !ok || ms != test.ms {
+			t.Errorf("%s.UnixMillis(): got %d, %v, want %d", test.src, ms, ok, test.ms)
+		}
+		if got := TimestampFromUnixMillis(ms).String(); got != test.back {
+			t.Errorf("TimestampFromUnixMillis(%d): got %s, want %s", ms, got, test.back)
+		}
+	}
+	for _, src := range []string{"null.timestamp", "5", `"2023-01-02"`} {
+		if _, ok := mustParse(t, src).UnixMillis(); ok {
+			t.Errorf("%s.UnixMillis() succeeded", src)
+		}
+	}
+}
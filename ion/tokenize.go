@@ -0,0 +1,50 @@
+package ion
+
+import (
+	"io"
+)
+
+// Tokenizer reads Ion text as a stream of raw tokens, including whitespace, for tools such as
+// syntax highlighters and formatters that work with the text rather than the values it holds.
+type Tokenizer struct {
+	// Comments makes Next return comments as COMMENT tokens, rather than skipping over them.
+	Comments bool
+
+	scanner *Scanner
+	closers []Token //the closing delimiters of the containers open at this point
+}
+
+// NewTokenizer returns a tokenizer reading from r.
+func NewTokenizer(r io.Reader) *Tokenizer {
+	return &Tokenizer{scanner: NewScanner(r)}
+}
+
+// Next returns the next token and its literal text, or EOF once the input is exhausted. Operators
+// such as + are only symbols inside an s-expression, which the tokenizer keeps track of. For an
+// ILLEGAL token, the error gives its position, and tokenizing can carry on after it.
+func (t *Tokenizer) Next() (Token, string, error) {
+	s := t.scanner
+	s.comments = t.Comments
+	s.sexp = len(t.closers) > 0 && t.closers[len(t.closers)-1] == CLOSE_PAREN
+	tok, lit := s.Scan()
+	switch {
+	case tok == ILLEGAL:
+		line, col := s.Position()
+		return tok, lit, &SyntaxError{Line: line, Col: col, Msg: illegalError(lit).Error()}
+	case tok == OPEN_BRACE:
+		t.closers = append(t.closers, CLOSE_BRACE)
+	case tok == OPEN_BRACKET:
+		t.closers = append(t.closers, CLOSE_BRACKET)
+	case tok == OPEN_PAREN:
+		t.closers = append(t.closers, CLOSE_PAREN)
+	case isCloser(tok) && len(t.closers) > 0 && t.closers[len(t.closers)-1] == tok:
+		t.closers = t.closers[:len(t.closers)-1]
+	}
+	return tok, lit, nil
+}
+
+// Position returns the line and column (both 1-based) where the token last returned by Next
+// started.
+func (t *Tokenizer) Position() (line, col int) {
+	return t.scanner.Position()
+}
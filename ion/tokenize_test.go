@@ -0,0 +1,82 @@
+package ion
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tokens returns every token from the tokenizer, as "TOKEN lit line:col", up to and including EOF.
+func tokens(tz *Tokenizer) []string {
+	var toks []string
+	for {
+		tok, lit, err := tz.Next()
+		line, col := tz.Position()
+		s := fmt.Sprintf("%v %q %d:%d", tok, lit, line, col)
+		if err != nil {
+			s += " " + err.Error()
+		}
+		toks = append(toks, s)
+		if tok == EOF {
+			return toks
+		}
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	src := "a::{b: (+ 1)} // c\n'x' +"
+	tests := []struct {
+		comments bool
+		want     []string
+	}{
+		{true, []string{
+			`SYMBOL "a" 1:1`,
+			`DOUBLE_COLON "::" 1:2`,
+			`OPEN_BRACE "{" 1:4`,
+			`SYMBOL "b" 1:5`,
+			`COLON ":" 1:6`,
+			`WHITESPACE " " 1:7`,
+			`OPEN_PAREN "(" 1:8`,
+			`SYMBOL "+" 1:9`,
+			`WHITESPACE " " 1:10`,
+			`NUMBER "1" 1:11`,
+			`CLOSE_PAREN ")" 1:12`,
+			`CLOSE_BRACE "}" 1:13`,
+			`WHITESPACE " " 1:14`,
+			`COMMENT "// c" 1:15`,
+			`WHITESPACE "\n" 1:19`,
+			`QUOTED_SYMBOL "x" 2:1`,
+			`WHITESPACE " " 2:4`,
+			`ILLEGAL "+" 2:5 2:5: Unexpected "+"`,
+			`EOF "" 2:6`,
+		}},
+		{false, []string{
+			`SYMBOL "a" 1:1`,
+			`DOUBLE_COLON "::" 1:2`,
+			`OPEN_BRACE "{" 1:4`,
+			`SYMBOL "b" 1:5`,
+			`COLON ":" 1:6`,
+			`WHITESPACE " " 1:7`,
+			`OPEN_PAREN "(" 1:8`,
+			`SYMBOL "+" 1:9`,
+			`WHITESPACE " " 1:10`,
+			`NUMBER "1" 1:11`,
+			`CLOSE_PAREN ")" 1:12`,
+			`CLOSE_BRACE "}" 1:13`,
+			`WHITESPACE " " 1:14`,
+			`WHITESPACE "\n" 1:19`, //after the skipped comment
+			`QUOTED_SYMBOL "x" 2:1`,
+			`WHITESPACE " " 2:4`,
+			`ILLEGAL "+" 2:5 2:5: Unexpected "+"`,
+			`EOF "" 2:6`,
+		}},
+	}
+	for _, test := range tests {
+		tz := NewTokenizer(strings.NewReader(src))
+		tz.Comments = test.comments
+		if got := tokens(tz); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("with Comments %v: got\n%s\nwant\n%s", test.comments, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
@@ -1,8 +1,14 @@
 package ion
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Type int
@@ -17,120 +23,611 @@ const (
 	StructType
 	ListType
 	SexpType
+	BlobType
+	ClobType
+	TimestampType
+	DecimalType
 )
 
-//a simplified view of what this can actually be
+var typeNames = map[Type]string{
+	NullType:      "null",
+	BoolType:      "bool",
+	IntType:       "int",
+	FloatType:     "float",
+	StringType:    "string",
+	SymbolType:    "symbol",
+	StructType:    "struct",
+	ListType:      "list",
+	SexpType:      "sexp",
+	BlobType:      "blob",
+	ClobType:      "clob",
+	TimestampType: "timestamp",
+	DecimalType:   "decimal",
+}
+
+func typeNamed(name string) (Type, bool) {
+	for t, n := range typeNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return NullType, false
+}
+
+// String returns the Ion name of the type, such as "int" or "struct".
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// a simplified view of what this can actually be. A typed null, such as null.int, has the Type
+// it is a null of, with Null set.
 type Value struct {
 	Type        Type
+	Null        bool
 	Annotations []string
 	Int         int64
+	BigInt      *big.Int //set instead of Int for an int outside the range of an int64
+	IntBase     int      //the base an int is written in: 16, 2, or 0 for base 10
 	Float       float64
+	Decimal     *Decimal
 	Text        string
+	SymbolID    bool //the symbol was written as a symbol ID, such as $10, which Text holds
+	Bytes       []byte
+	Time        time.Time
+	Precision   TimestampPrecision
 	Sequence    []Value
 	Struct      []Field
+	Comments    []string //the comments just before the value, if the parser's KeepComments is set
 }
 
 type Field struct {
 	Name  string
 	Value Value
+
+	// NameAnnotations are annotations on the field name itself, as in {a::name: 1}. They are only
+	// parsed when the parser's FieldNameAnnotations option is set.
+	NameAnnotations []string
 }
 
 func (v Value) String() string {
+	var p printer
+	p.print(v)
+	return p.buf.String()
+}
+
+// ColorString returns the same text as String, with ANSI color codes around strings, numbers,
+// symbols, field names, and annotations for display on a terminal. If color is false, as a command
+// would pass when the NO_COLOR environment variable is set, the codes are omitted.
+func (v Value) ColorString(color bool) string {
+	p := printer{color: color}
+	p.print(v)
+	return p.buf.String()
+}
+
+// PrettyString returns the text of the value with each struct field and sequence element on its own
+// line, indented by one more copy of indent at each level of nesting.
+func (v Value) PrettyString(indent string) string {
+	return v.Formatted(WriteOptions{Style: BlockStyle, Indent: indent})
+}
+
+// Describe returns a one-line summary of the shape of the value, without its contents, such as
+// struct{name:string, age:int, tags:list[3]}, for logging a document without exposing its data.
+// Structs show the type of each field, lists and sexps show only how many items they have, and
+// nulls show as null or a typed null such as null.int.
+func (v *Value) Describe() string {
+	var buf strings.Builder
+	describe(&buf, *v)
+	return buf.String()
+}
+
+func describe(buf *strings.Builder, v Value) {
+	switch {
+	case v.Type == NullType:
+		buf.WriteString("null")
+	case v.Null:
+		buf.WriteString("null." + v.Type.String())
+	case v.Type == StructType:
+		buf.WriteString("struct{")
+		for i, field := range v.Struct {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(field.Name)
+			buf.WriteByte(':')
+			describe(buf, field.Value)
+		}
+		buf.WriteByte('}')
+	case v.Type == ListType || v.Type == SexpType:
+		fmt.Fprintf(buf, "%v[%d]", v.Type, len(v.Sequence))
+	default:
+		buf.WriteString(v.Type.String())
+	}
+}
+
+// IsNull reports whether the value is a null, either plain or typed.
+func (v Value) IsNull() bool {
+	return v.Type == NullType || v.Null
+}
+
+// typeName returns the name of the value's type for error messages, such as int, or null.int for
+// a typed null.
+func (v Value) typeName() string {
+	if v.Null && v.Type != NullType {
+		return "null." + v.Type.String()
+	}
+	return v.Type.String()
+}
+
+// CountField returns the number of fields in the struct with the given name. Ion allows duplicate
+// field names, so this can be more than one. It returns 0 if the value is not a struct.
+func (v *Value) CountField(name string) int {
+	if v.Type != StructType {
+		return 0
+	}
+	count := 0
+	for _, field := range v.Struct {
+		if field.Name == name {
+			count++
+		}
+	}
+	return count
+}
+
+// Fields returns the fields of a struct, or nil if the value is not a struct.
+func (v Value) Fields() []Field {
+	if v.Type != StructType {
+		return nil
+	}
+	return v.Struct
+}
+
+// Get returns the value of the first field with the given name, and false if there is none or
+// the value is not a struct. Ion structs may repeat a field name; GetAll returns every value.
+func (v Value) Get(name string) (Value, bool) {
+	for _, field := range v.Fields() {
+		if field.Name == name {
+			return field.Value, true
+		}
+	}
+	return Value{}, false
+}
+
+// GetAll returns the values of every field with the given name, in the order they appear, or nil
+// if there are none or the value is not a struct.
+func (v Value) GetAll(name string) []Value {
+	var values []Value
+	for _, field := range v.Fields() {
+		if field.Name == name {
+			values = append(values, field.Value)
+		}
+	}
+	return values
+}
+
+// Path returns the value at a path within the value, written as field names separated by dots and
+// sequence indices in brackets, as in a.b[2].c. A path may begin with an index, as in [0].name,
+// and the empty path is the value itself. Field names cannot contain '.' or '[', and where a name
+// is repeated, the first field is used, as with Get. It returns false if a field is missing, an
+// index is out of range, or the path is malformed, including an index that is not just digits, such
+// as [+1].
+func (v Value) Path(expr string) (Value, bool) {
+	cur := v
+	for i := 0; i < len(expr); {
+		if expr[i] == '[' {
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return Value{}, false
+			}
+			index := expr[i+1 : i+end]
+			if !isDigits(index) {
+				return Value{}, false
+			}
+			n, err := strconv.Atoi(index)
+			if err != nil || (cur.Type != ListType && cur.Type != SexpType) || n >= len(cur.Sequence) {
+				return Value{}, false
+			}
+			cur = cur.Sequence[n]
+			i += end + 1
+			continue
+		}
+		if i > 0 {
+			if expr[i] != '.' {
+				return Value{}, false
+			}
+			i++
+		}
+		end := strings.IndexAny(expr[i:], ".[")
+		if end < 0 {
+			end = len(expr) - i
+		}
+		if end == 0 {
+			return Value{}, false
+		}
+		val, ok := cur.Get(expr[i : i+end])
+		if !ok {
+			return Value{}, false
+		}
+		cur = val
+		i += end
+	}
+	return cur, true
+}
+
+// SID returns the ID of a symbol written as a symbol ID, such as 10 for $10, so that it can be
+// resolved against a symbol table. It returns false for any other value.
+func (v Value) SID() (int, bool) {
+	if v.Type != SymbolType || !v.SymbolID || len(v.Text) < 2 || v.Text[0] != '$' {
+		return 0, false
+	}
+	sid, err := strconv.Atoi(v.Text[1:])
+	return sid, err == nil
+}
+
+// Require checks that a struct has a non-null field for each of the names, as when validating a
+// config. The error lists every name that is missing or null.
+func (v *Value) Require(names ...string) error {
+	if v.Type != StructType || v.Null {
+		return fmt.Errorf("Cannot require fields of a value of type %s, expected a struct", v.typeName())
+	}
+	var missing []string
+	for _, name := range names {
+		if field, ok := v.Get(name); !ok || field.IsNull() {
+			missing = append(missing, name)
+		}
+	}
+	if missing != nil {
+		return fmt.Errorf("Missing required fields: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// GetString returns the text of the first field with the given name, if it is a non-null string.
+func (v Value) GetString(name string) (string, bool) {
+	field, ok := v.Get(name)
+	if !ok || field.Type != StringType || field.Null {
+		return "", false
+	}
+	return field.Text, true
+}
+
+// GetInt returns the first field with the given name, if it is a non-null int.
+func (v Value) GetInt(name string) (int64, bool) {
+	field, ok := v.Get(name)
+	if !ok {
+		return 0, false
+	}
+	return field.AsInt()
+}
+
+// AsInt returns the value of a non-null int, and false for anything else.
+func (v *Value) AsInt() (int64, bool) {
+	if v.Type != IntType || v.Null || v.BigInt != nil {
+		return 0, false
+	}
+	return v.Int, true
+}
+
+// bigInt returns the value of an int as a big.Int, however it is stored.
+func (v Value) bigInt() *big.Int {
+	if v.BigInt != nil {
+		return v.BigInt
+	}
+	return big.NewInt(v.Int)
+}
+
+// AsIntCoerce is like AsInt, but also accepts a string or symbol whose text is a decimal integer,
+// such as "30", for loosely typed data.
+func (v *Value) AsIntCoerce() (int64, bool) {
+	if (v.Type == StringType || v.Type == SymbolType) && !v.Null {
+		n, err := strconv.ParseInt(v.Text, 10, 64)
+		return n, err == nil
+	}
+	return v.AsInt()
+}
+
+// Append adds items to the end of a list or sexp. It returns an error for any other type.
+func (v *Value) Append(items ...Value) error {
+	if v.Type != ListType && v.Type != SexpType {
+		return fmt.Errorf("Cannot append to a value of type %s, expected a list or sexp", v.typeName())
+	}
+	v.Sequence = append(v.Sequence, items...)
+	return nil
+}
+
+// ShardBy partitions the structs of a list into n lists by a hash of their keyField value, so that
+// structs with equal keys always land in the same shard. A struct without the field is sharded as
+// if its key were null. It returns an error if the value is not a list of structs.
+func (v *Value) ShardBy(keyField string, n int) ([]Value, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("Invalid shard count: %d", n)
+	}
+	if v.Type != ListType || v.Null {
+		return nil, fmt.Errorf("Cannot shard a value of type %s, expected a list", v.typeName())
+	}
+	shards := make([]Value, n)
+	for i := range shards {
+		shards[i] = Value{Type: ListType, Sequence: make([]Value, 0)}
+	}
+	for _, item := range v.Sequence {
+		if item.Type != StructType || item.Null {
+			return nil, fmt.Errorf("Cannot shard a list element of type %s, expected a struct", item.typeName())
+		}
+		key := Value{Type: NullType}
+		for _, field := range item.Struct {
+			if field.Name == keyField {
+				key = field.Value
+				break
+			}
+		}
+		h := fnv.New32a()
+		h.Write([]byte(key.Normalized().String()))
+		shard := &shards[h.Sum32()%uint32(n)]
+		shard.Sequence = append(shard.Sequence, item)
+	}
+	return shards, nil
+}
+
+// PathTo returns the path from the value to target, which must point into the value's own tree:
+// the field names and sequence indices, written as "[0]" as in Walk, to follow in order. Values are
+// matched by identity rather than content, so of several equal values only the one target points at
+// is found. It returns false if target is not within the value.
+func (v *Value) PathTo(target *Value) ([]string, bool) {
+	if v == target {
+		return []string{}, true
+	}
 	switch v.Type {
-	case NullType:
-		return "null"
-	case BoolType:
-		if v.Int == 0 {
-			return "false"
-		}
-		return "true"
-	case IntType:
-		return fmt.Sprintf("%d", v.Int)
-	case FloatType:
-		return fmt.Sprintf("%g", v.Float)
-	case StringType:
-		return fmt.Sprintf("%q", v.Text)
-	case SymbolType:
-		return symbolToString(v.Text)
 	case StructType:
-		return annotate(v) + structToString(v.Struct)
-	case ListType:
-		return sequenceToString(v.Sequence, '[', ',', ']')
-	case SexpType:
-		return sequenceToString(v.Sequence, '(', 0, ')')
-	default:
-		return "?FIXME?"
+		for i := range v.Struct {
+			if path, ok := v.Struct[i].Value.PathTo(target); ok {
+				return append([]string{v.Struct[i].Name}, path...), true
+			}
+		}
+	case ListType, SexpType:
+		for i := range v.Sequence {
+			if path, ok := v.Sequence[i].PathTo(target); ok {
+				return append([]string{"[" + strconv.Itoa(i) + "]"}, path...), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// StopWalk can be returned by the function given to Walk to stop visiting values. Walk itself then
+// returns nil.
+var StopWalk = errors.New("stop walk")
+
+// Walk calls fn for the value and then, depth first, for every value nested within it, in order.
+// The path to each value lists the struct field names and sequence indices, written as "[0]", that
+// lead to it from the root, whose path is empty. If fn returns an error, the walk stops and Walk
+// returns that error, unless it is StopWalk.
+func (v Value) Walk(fn func(path []string, v Value) error) error {
+	if err := walk(nil, v, fn); err != StopWalk {
+		return err
 	}
+	return nil
 }
 
-func annotate(val Value) string {
-	if len(val.Annotations) > 0 {
-		var buf bytes.Buffer
-		for _, anno := range val.Annotations {
-			buf.WriteString(anno)
-			buf.WriteString("::")
+func walk(path []string, v Value, fn func([]string, Value) error) error {
+	if err := fn(path, v); err != nil {
+		return err
+	}
+	//each child's path gets its own array, so fn can keep the paths it is given
+	path = path[:len(path):len(path)]
+	for _, field := range v.Struct {
+		if err := walk(append(path, field.Name), field.Value, fn); err != nil {
+			return err
 		}
-		return buf.String()
 	}
-	return ""
+	for i, item := range v.Sequence {
+		if err := walk(append(path, "["+strconv.Itoa(i)+"]"), item, fn); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func symbolToString(val string) string {
-	//to do: escape embedded single quotes
-	//for now, also single-quote, so we can distinguish them from keywords when debugging
-	//if strings.Index(val, " ") >= 0 {
-	return fmt.Sprintf("'%s'", val)
-	//}
-	//return val
+// PruneEmpty returns a copy of the value with empty structs, empty lists, and null-valued struct
+// fields removed at every level. A struct or list that becomes empty once its own contents are
+// pruned is removed too. Empty sexps are kept, since () is meaningful on its own.
+func (v Value) PruneEmpty() Value {
+	pruned, _ := prune(v)
+	return pruned
 }
 
-func structToString(fields []Field) string {
-	switch len(fields) {
-	case 0:
-		return "{}"
-	case 1:
-		return "{" + fields[0].Name + ": " + fields[0].Value.String() + "}"
-	default:
-		var buf bytes.Buffer
-		buf.WriteRune('{')
-		first := true
-		for _, item := range fields {
-			if first {
-				first = false
-			} else {
-				buf.WriteString(", ")
+// prune returns the pruned copy of a value, and whether the value itself should be removed from its parent.
+func prune(v Value) (Value, bool) {
+	if v.Annotations != nil {
+		v.Annotations = append([]string(nil), v.Annotations...)
+	}
+	switch v.Type {
+	case StructType:
+		fields := make([]Field, 0, len(v.Struct))
+		for _, field := range v.Struct {
+			if field.Value.IsNull() {
+				continue
+			}
+			if val, empty := prune(field.Value); !empty {
+				field.Value = val
+				fields = append(fields, field)
 			}
-			buf.WriteString(item.Name)
-			buf.WriteString(": ")
-			buf.WriteString(item.Value.String())
 		}
-		buf.WriteRune('}')
-		return buf.String()
+		v.Struct = fields
+		return v, len(fields) == 0
+	case ListType, SexpType:
+		items := make([]Value, 0, len(v.Sequence))
+		for _, item := range v.Sequence {
+			if val, empty := prune(item); !empty {
+				items = append(items, val)
+			}
+		}
+		v.Sequence = items
+		return v, v.Type == ListType && len(items) == 0
 	}
+	return v, false
 }
 
-func sequenceToString(values []Value, openChar, delimChar, closeChar rune) string {
-	switch len(values) {
-	case 0:
-		return string(openChar) + string(closeChar)
-	case 1:
-		return string(openChar) + values[0].String() + string(closeChar)
-	default:
-		var buf bytes.Buffer
-		buf.WriteRune(openChar)
-		first := true
-		for _, item := range values {
-			if first {
-				first = false
-			} else {
-				if delimChar != 0 {
-					buf.WriteRune(delimChar)
-				}
-				buf.WriteRune(' ')
+// Merge returns a copy of the struct v with the fields of the struct patch laid over it, as for
+// overriding a base config. A field in the patch replaces the field of the same name in v, or is
+// added after v's fields if v has none. Where both are structs, they are merged the same way, at
+// every level; anything else, lists included, is replaced whole. A field whose value in the patch
+// is an untyped null removes the field from v instead, while a typed null such as null.string
+// replaces it like any other value. The annotations of v are kept.
+//
+// If v has more than one field with a name the patch replaces, the first takes the patch's value
+// and the rest are removed.
+func (v Value) Merge(patch Value) (Value, error) {
+	if v.Type != StructType || v.Null {
+		return Value{}, fmt.Errorf("Cannot merge into a value of type %s, expected a struct", v.typeName())
+	}
+	if patch.Type != StructType || patch.Null {
+		return Value{}, fmt.Errorf("Cannot merge a patch of type %s, expected a struct", patch.typeName())
+	}
+	return merge(v.Clone(), patch), nil
+}
+
+// merge lays the fields of patch over those of v, which is a copy that can be modified.
+func merge(v Value, patch Value) Value {
+	for _, pf := range patch.Struct {
+		fields := v.Struct[:0]
+		replaced := false
+		for _, field := range v.Struct {
+			switch {
+			case field.Name != pf.Name:
+				fields = append(fields, field)
+			case pf.Value.Type == NullType || replaced:
+				//removed
+			case isStruct(field.Value) && isStruct(pf.Value):
+				field.Value = merge(field.Value, pf.Value)
+				fields = append(fields, field)
+				replaced = true
+			default:
+				field.Value = pf.Value.Clone()
+				fields = append(fields, field)
+				replaced = true
 			}
-			buf.WriteString(item.String())
 		}
-		buf.WriteRune(closeChar)
-		return buf.String()
+		if !replaced && pf.Value.Type != NullType {
+			pf.Value = pf.Value.Clone()
+			fields = append(fields, pf)
+		}
+		v.Struct = fields
+	}
+	return v
+}
+
+func isStruct(v Value) bool {
+	return v.Type == StructType && !v.Null
+}
+
+// Clone returns a deep copy of the value, sharing nothing with the original, so either can be
+// modified without affecting the other.
+func (v Value) Clone() Value {
+	if v.Annotations != nil {
+		v.Annotations = append([]string(nil), v.Annotations...)
+	}
+	if v.Bytes != nil {
+		v.Bytes = append([]byte(nil), v.Bytes...)
+	}
+	if v.Comments != nil {
+		v.Comments = append([]string(nil), v.Comments...)
+	}
+	if v.BigInt != nil {
+		v.BigInt = new(big.Int).Set(v.BigInt)
+	}
+	if v.Decimal != nil {
+		d := *v.Decimal
+		d.Coefficient = new(big.Int).Set(d.Coefficient)
+		v.Decimal = &d
+	}
+	if v.Sequence != nil {
+		items := make([]Value, len(v.Sequence))
+		for i, item := range v.Sequence {
+			items[i] = item.Clone()
+		}
+		v.Sequence = items
+	}
+	if v.Struct != nil {
+		fields := make([]Field, len(v.Struct))
+		for i, field := range v.Struct {
+			fields[i] = Field{Name: field.Name, Value: field.Value.Clone()}
+			if field.NameAnnotations != nil {
+				fields[i].NameAnnotations = append([]string(nil), field.NameAnnotations...)
+			}
+		}
+		v.Struct = fields
+	}
+	return v
+}
+
+// ReplaceAll returns a deep copy of the value in which every value, at any level, for which pred
+// returns true is replaced by the result of replacement. The contents of a replaced value are not
+// visited. The value given to pred and replacement is already a copy, so it can be modified.
+func (v Value) ReplaceAll(pred func(*Value) bool, replacement func(Value) Value) Value {
+	c := v.Clone()
+	replaceAll(&c, pred, replacement)
+	return c
+}
+
+func replaceAll(v *Value, pred func(*Value) bool, replacement func(Value) Value) {
+	if pred(v) {
+		*v = replacement(*v)
+		return
+	}
+	for i := range v.Sequence {
+		replaceAll(&v.Sequence[i], pred, replacement)
+	}
+	for i := range v.Struct {
+		replaceAll(&v.Struct[i].Value, pred, replacement)
+	}
+}
+
+// Normalized returns a copy of the value with the fields of every struct sorted by name, keeping
+// duplicate names in their original order. Sequences keep their order, since it is significant.
+// Two structs that differ only in field order have identical normalized forms, which makes them
+// convenient to diff.
+func (v Value) Normalized() Value {
+	if v.Annotations != nil {
+		v.Annotations = append([]string(nil), v.Annotations...)
+	}
+	switch v.Type {
+	case StructType:
+		fields := make([]Field, len(v.Struct))
+		for i, field := range v.Struct {
+			fields[i] = field
+			fields[i].Value = field.Value.Normalized()
+		}
+		sort.SliceStable(fields, func(i, j int) bool {
+			return fields[i].Name < fields[j].Name
+		})
+		v.Struct = fields
+	case ListType, SexpType:
+		items := make([]Value, len(v.Sequence))
+		for i, item := range v.Sequence {
+			items[i] = item.Normalized()
+		}
+		v.Sequence = items
+	}
+	return v
+}
+
+// FromRows builds a list of structs, one per row, such as the rows of a database query. Each
+// struct's fields are sorted by name, so the result does not depend on map iteration order.
+func FromRows(rows []map[string]Value) Value {
+	list := make([]Value, 0, len(rows))
+	for _, row := range rows {
+		names := make([]string, 0, len(row))
+		for name := range row {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fields := make([]Field, 0, len(names))
+		for _, name := range names {
+			fields = append(fields, Field{Name: name, Value: row[name]})
+		}
+		list = append(list, Value{Type: StructType, Struct: fields})
 	}
+	return Value{Type: ListType, Sequence: list}
 }
@@ -0,0 +1,562 @@
+package ion
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCountField(t *testing.T) {
+	tests := []struct {
+		src   string
+		name  string
+		count int
+	}{
+		{"{a: 1}", "b", 0},
+		{"{a: 1, b: 2}", "a", 1},
+		{"{a: 1, b: 2, a: 3, a: 4}", "a", 3},
+		{"{}", "a", 0},
+		{"[a, a]", "a", 0},
+		{"a", "a", 0},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if got := v.CountField(test.name); got != test.count {
+			t.Errorf("%s.CountField(%q): got %d, want %d", test.src, test.name, got, test.count)
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	tests := []struct {
+		src   string
+		items []string
+		want  string
+		err   string
+	}{
+		{"[]", []string{"1", `"two"`}, `[1, "two"]`, ""},
+		{"[1]", []string{"2"}, "[1, 2]", ""},
+		{"[]", nil, "[]", ""},
+		{"(1)", []string{"2"}, "(1 2)", ""},
+		{"{}", []string{"1"}, "{}", "Cannot append to a value of type struct, expected a list or sexp"},
+		{"1", []string{"2"}, "1", "Cannot append to a value of type int, expected a list or sexp"},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		var items []Value
+		for _, item := range test.items {
+			items = append(items, *mustParse(t, item))
+		}
+		err := v.Append(items...)
+		if got := errorString(err); got != test.err {
+			t.Errorf("Append to %s: got error %q, want %q", test.src, got, test.err)
+		}
+		if got := v.String(); got != test.want {
+			t.Errorf("Append to %s: got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestPruneEmpty(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"{a: 1, b: {}, c: [], d: null}", "{a: 1}"},
+		{"{a: {b: {c: {}}}, d: 2}", "{d: 2}"},
+		{"{a: [[], {}, 1], b: [[{}]]}", "{a: [1]}"},
+		{"{a: (), b: (1 ())}", "{a: (), b: (1 ())}"},
+		{"{a: null.string, b: ann::{}}", "{}"},
+		{"[{}, [], 1]", "[1]"},
+		{"{}", "{}"},
+		{"5", "5"},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if got := v.PruneEmpty().String(); got != test.want {
+			t.Errorf("PruneEmpty(%s): got %s, want %s", test.src, got, test.want)
+		}
+		if got := v.String(); got != mustParse(t, test.src).String() {
+			t.Errorf("PruneEmpty(%s) modified its receiver, which is now %s", test.src, got)
+		}
+	}
+}
+
+func TestNormalized(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{"{b: 2, a: 1}", "{a: 1, b: 2}", "{a: 1, b: 2}"},
+		{"{z: {y: 1, x: 2}, a: [{d: 1, c: 2}]}", "{a: [{c: 2, d: 1}], z: {x: 2, y: 1}}",
+			"{a: [{c: 2, d: 1}], z: {x: 2, y: 1}}"},
+		{"[3, 1, 2]", "[3, 1, 2]", "[3, 1, 2]"},
+		{"t::{b: (y x), a: 1}", "t::{a: 1, b: (y x)}", "t::{a: 1, b: (y x)}"},
+	}
+	for _, test := range tests {
+		a, b := mustParse(t, test.a).Normalized(), mustParse(t, test.b).Normalized()
+		if got := a.String(); got != test.want {
+			t.Errorf("%s.Normalized(): got %s, want %s", test.a, got, test.want)
+		}
+		if !a.Equal(b) || a.String() != b.String() {
+			t.Errorf("%s and %s normalize to %s and %s", test.a, test.b, a, b)
+		}
+	}
+}
+
+func TestFromRows(t *testing.T) {
+	tests := []struct {
+		rows []map[string]Value
+		want string
+	}{
+		{nil, "[]"},
+		{[]map[string]Value{{}}, "[{}]"},
+		{
+			[]map[string]Value{
+				{"name": Str("ann"), "id": Int(1), "tags": NewList(Sym("a"))},
+				{"name": Str("bob"), "id": Int(2), "manager": Null()},
+			},
+			`[{id: 1, name: "ann", tags: [a]}, {id: 2, manager: null, name: "bob"}]`,
+		},
+	}
+	for _, test := range tests {
+		if got := FromRows(test.rows).String(); got != test.want {
+			t.Errorf("FromRows(%v): got %s, want %s", test.rows, got, test.want)
+		}
+	}
+}
+
+func TestAsIntCoerce(t *testing.T) {
+	tests := []struct {
+		src    string
+		n      int64
+		ok     bool
+		strict bool
+	}{
+		{"30", 30, true, true},
+		{"-7", -7, true, true},
+		{`"30"`, 30, true, false},
+		{`"-30"`, -30, true, false},
+		{"'42'", 42, true, false},
+		{`"thirty"`, 0, false, false},
+		{`"3.5"`, 0, false, false},
+		{`""`, 0, false, false},
+		{"null.string", 0, false, false},
+		{"null.int", 0, false, false},
+		{"1.5", 0, false, false},
+		{"99999999999999999999", 0, false, false},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if n, ok := v.AsIntCoerce(); n != test.n || ok != test.ok {
+			t.Errorf("%s.AsIntCoerce(): got %d, %v, want %d, %v", test.src, n, ok, test.n, test.ok)
+		}
+		if _, ok := v.AsInt(); ok != test.strict {
+			t.Errorf("%s.AsInt(): got %v, want %v", test.src, ok, test.strict)
+		}
+	}
+}
+
+func TestShardBy(t *testing.T) {
+	list := NewList()
+	for i := 0; i < 1000; i++ {
+		list.Append(NewStruct().Field("id", Int(int64(i))).Field("k", Str(fmt.Sprintf("key%d", i%50))))
+	}
+	list.Append(NewStruct().Field("id", Int(1000)))
+	shards, err := list.ShardBy("k", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := list.ShardBy("k", 4)
+	home := make(map[string]int)
+	total := 0
+	for i, shard := range shards {
+		if !shard.Equal(again[i]) {
+			t.Errorf("shard %d differs between runs", i)
+		}
+		if n := len(shard.Sequence); n < 100 || n > 450 {
+			t.Errorf("shard %d has %d of 1001 items", i, n)
+		}
+		total += len(shard.Sequence)
+		for _, item := range shard.Sequence {
+			key, _ := item.Get("k")
+			if j, ok := home[key.String()]; ok && j != i {
+				t.Errorf("key %s is in shards %d and %d", key, j, i)
+			}
+			home[key.String()] = i
+		}
+	}
+	if total != 1001 {
+		t.Errorf("the shards hold %d items, want 1001", total)
+	}
+}
+
+func TestShardByErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		n   int
+		err string
+	}{
+		{"[{k: 1}]", 0, "Invalid shard count: 0"},
+		{"{k: 1}", 2, "Cannot shard a value of type struct, expected a list"},
+		{"null.list", 2, "Cannot shard a value of type null.list, expected a list"},
+		{"[{k: 1}, 2]", 2, "Cannot shard a list element of type int, expected a struct"},
+	}
+	for _, test := range tests {
+		_, err := mustParse(t, test.src).ShardBy("k", test.n)
+		if got := errorString(err); got != test.err {
+			t.Errorf("ShardBy(%s, %d): got error %q, want %q", test.src, test.n, got, test.err)
+		}
+	}
+}
+
+func TestPathTo(t *testing.T) {
+	v := mustParse(t, `{a: 1, b: [x, {c: (p q)}, x], a: 2}`)
+	list := &v.Struct[1].Value
+	tests := []struct {
+		target *Value
+		want   []string
+	}{
+		{v, []string{}},
+		{&v.Struct[0].Value, []string{"a"}},
+		{&v.Struct[2].Value, []string{"a"}},
+		{list, []string{"b"}},
+		{&list.Sequence[0], []string{"b", "[0]"}},
+		{&list.Sequence[2], []string{"b", "[2]"}},
+		{&list.Sequence[1].Struct[0].Value.Sequence[1], []string{"b", "[1]", "c", "[1]"}},
+	}
+	for _, test := range tests {
+		path, ok := v.PathTo(test.target)
+		if !ok || !reflect.DeepEqual(path, test.want) {
+			t.Errorf("PathTo(%s): got %q, %v, want %q", test.target, path, ok, test.want)
+		}
+	}
+	equal := list.Sequence[0].Clone()
+	if path, ok := v.PathTo(&equal); ok {
+		t.Errorf("PathTo found a copy of x at %q", path)
+	}
+}
+
+func TestClone(t *testing.T) {
+	const src = `ann::{a: [1, {b: 2}], c: (x y), d: {{aGk=}}}`
+	orig := mustParse(t, src)
+	clone := orig.Clone()
+	if !clone.Equal(*orig) {
+		t.Fatalf("Clone(%s) = %s", src, clone)
+	}
+	clone.Annotations[0] = "changed"
+	list := &clone.Struct[0].Value
+	list.Sequence[0] = Int(100)
+	list.Sequence = append(list.Sequence, Int(3))
+	list.Sequence[1].Struct[0].Value = Str("two")
+	clone.Struct[1].Value.Sequence[0].Text = "z"
+	clone.Struct[2].Value.Bytes[0] = 'H'
+	clone.Struct = append(clone.Struct, Field{Name: "e", Value: Int(5)})
+	if got, want := orig.String(), mustParse(t, src).String(); got != want {
+		t.Errorf("mutating the clone changed the original to %s, want %s", got, want)
+	}
+}
+
+func TestFieldAccessors(t *testing.T) {
+	v := mustParse(t, `{name: "ann", id: 7, sym: s, n: null.string, id: 8, big: 99999999999999999999}`)
+	tests := []struct {
+		name  string
+		get   string //the text of Get's value, or "" if Get fails
+		str   string
+		strOK bool
+		n     int64
+		nOK   bool
+	}{
+		{"name", `"ann"`, "ann", true, 0, false},
+		{"id", "7", "", false, 7, true},
+		{"sym", "s", "", false, 0, false},
+		{"n", "null.string", "", false, 0, false},
+		{"big", "99999999999999999999", "", false, 0, false},
+		{"missing", "", "", false, 0, false},
+	}
+	for _, test := range tests {
+		got, ok := v.Get(test.name)
+		if ok != (test.get != "") || (ok && got.String() != test.get) {
+			t.Errorf("Get(%q): got %s, %v, want %s", test.name, got, ok, test.get)
+		}
+		if s, ok := v.GetString(test.name); s != test.str || ok != test.strOK {
+			t.Errorf("GetString(%q): got %q, %v, want %q, %v", test.name, s, ok, test.str, test.strOK)
+		}
+		if n, ok := v.GetInt(test.name); n != test.n || ok != test.nOK {
+			t.Errorf("GetInt(%q): got %d, %v, want %d, %v", test.name, n, ok, test.n, test.nOK)
+		}
+	}
+	if got := len(v.Fields()); got != 6 {
+		t.Errorf("Fields(): got %d fields, want 6", got)
+	}
+	for _, src := range []string{"[1]", "5", "null.struct"} {
+		nonStruct := mustParse(t, src)
+		if _, ok := nonStruct.Get("a"); ok || nonStruct.Fields() != nil {
+			t.Errorf("the accessors of %s found fields", src)
+		}
+	}
+}
+
+func TestReplaceAll(t *testing.T) {
+	const src = `{user: "ann", password: secret::"hunter2", keys: [secret::{k: 1}, "public"], n: secret::5}`
+	v := mustParse(t, src)
+	secret := func(v *Value) bool {
+		return len(v.Annotations) > 0 && v.Annotations[0] == "secret"
+	}
+	redact := func(Value) Value { return Str("***") }
+	want := `{user: "ann", password: "***", keys: ["***", "public"], n: "***"}`
+	if got := v.ReplaceAll(secret, redact).String(); got != want {
+		t.Errorf("redacting secrets: got %s, want %s", got, want)
+	}
+	if got := v.String(); got != mustParse(t, src).String() {
+		t.Errorf("ReplaceAll changed its receiver to %s", got)
+	}
+	double := v.ReplaceAll(func(v *Value) bool { return v.Type == IntType }, func(v Value) Value {
+		v.Int *= 2
+		return v
+	})
+	if got, want := double.String(), `{user: "ann", password: secret::"hunter2", keys: [secret::{k: 2}, "public"], n: secret::10}`; got != want {
+		t.Errorf("doubling ints: got %s, want %s", got, want)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	tests := []struct {
+		src   string
+		names []string
+		err   string
+	}{
+		{"{host: \"a\", port: 80}", []string{"host", "port"}, ""},
+		{"{host: \"a\", user: null}", []string{"host", "port", "user"}, "Missing required fields: port, user"},
+		{"{}", []string{"host"}, "Missing required fields: host"},
+		{"{host: \"a\"}", nil, ""},
+		{"[1, 2]", []string{"host"}, "Cannot require fields of a value of type list, expected a struct"},
+		{"null.struct", []string{"host"}, "Cannot require fields of a value of type null.struct, expected a struct"},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if got := errorString(v.Require(test.names...)); got != test.err {
+			t.Errorf("%s.Require(%q): got error %q, want %q", test.src, test.names, got, test.err)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"1", "int"},
+		{"null", "null"},
+		{"null.string", "null.string"},
+		{"(a b)", "sexp[2]"},
+		{`{name: "x", age: 3, tags: [a, b, c]}`, "struct{name:string, age:int, tags:list[3]}"},
+		{`{user: {name: "x", born: 2000-01-01T}, score: 1.5, ok: null.bool}`, "struct{user:struct{name:string, born:timestamp}, score:decimal, ok:null.bool}"},
+		{"{}", "struct{}"},
+	}
+	for _, test := range tests {
+		if got := mustParse(t, test.src).Describe(); got != test.want {
+			t.Errorf("%s.Describe(): got %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	v := mustParse(t, `{name: "a", tags: ["b", 1, {note: "c"}], n: 2, more: ("d")}`)
+	var got []string
+	err := v.Walk(func(path []string, v Value) error {
+		if v.Type == StringType {
+			got = append(got, fmt.Sprintf("%v=%s", path, v.Text))
+		}
+		return nil
+	})
+	want := []string{"[name]=a", "[tags [0]]=b", "[tags [2] note]=c", "[more [0]]=d"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk: got %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestWalkStop(t *testing.T) {
+	v := mustParse(t, `[1, [2, 3], 4]`)
+	errBad := fmt.Errorf("bad")
+	tests := []struct {
+		stop, want error
+	}{
+		{StopWalk, nil},
+		{errBad, errBad},
+	}
+	for _, test := range tests {
+		visited := 0
+		err := v.Walk(func(path []string, v Value) error {
+			visited++
+			if v.Type == IntType && v.Int == 2 {
+				return test.stop
+			}
+			return nil
+		})
+		if visited != 4 || err != test.want {
+			t.Errorf("Walk stopping with %v: visited %d values and returned %v, want 4 and %v", test.stop, visited, err, test.want)
+		}
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	v := mustParse(t, "{a:1, a:2, b:3}")
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"a", []string{"1", "2"}},
+		{"b", []string{"3"}},
+		{"c", nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, val := range v.GetAll(test.name) {
+			got = append(got, val.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("GetAll(%s): got %q, want %q", test.name, got, test.want)
+		}
+	}
+	if first, ok := v.Get("a"); !ok || first.String() != "1" {
+		t.Errorf("Get(a): got %v, %v, want the first field, 1", first, ok)
+	}
+	if got := v.String(); got != "{a: 1, a: 2, b: 3}" {
+		t.Errorf("String: got %s, want every field kept", got)
+	}
+	if got := mustParse(t, "[1]").GetAll("a"); got != nil {
+		t.Errorf("[1].GetAll(a): got %v, want nil", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		base, patch, want string
+	}{
+		{"{a: 1, b: 2}", "{b: 3, c: 4}", "{a: 1, b: 3, c: 4}"},
+		{"{db: {host: x, port: 1}, v: 1}", "{db: {port: 2, user: u}}", "{db: {host: x, port: 2, user: u}, v: 1}"},
+		{"{list: [1, 2, 3]}", "{list: [4]}", "{list: [4]}"},
+		{"{a: {b: 1}}", "{a: 5}", "{a: 5}"},
+		{"{a: 5}", "{a: {b: 1}}", "{a: {b: 1}}"},
+		{"{a: 1, b: 2}", "{a: null}", "{b: 2}"},
+		{"{a: {b: 1, c: 2}}", "{a: {c: null}}", "{a: {b: 1}}"},
+		{"{a: 1}", "{a: null.string}", "{a: null.string}"},
+		{"{a: 1}", "{z: null}", "{a: 1}"},
+		{"{a: 1, b: 2, a: 3}", "{a: 4}", "{a: 4, b: 2}"},
+		{"cfg::{a: 1}", "p::{a: 2}", "cfg::{a: 2}"},
+	}
+	for _, test := range tests {
+		base, patch := mustParse(t, test.base), mustParse(t, test.patch)
+		merged, err := base.Merge(*patch)
+		if err != nil {
+			t.Errorf("%s.Merge(%s): %v", test.base, test.patch, err)
+			continue
+		}
+		if got := merged.String(); got != test.want {
+			t.Errorf("%s.Merge(%s): got %s, want %s", test.base, test.patch, got, test.want)
+		}
+		if got := base.String(); got != test.base {
+			t.Errorf("%s.Merge(%s) changed the base to %s", test.base, test.patch, got)
+		}
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	tests := []struct {
+		base, patch, err string
+	}{
+		{"[1]", "{a: 1}", "Cannot merge into a value of type list, expected a struct"},
+		{"null.struct", "{a: 1}", "Cannot merge into a value of type null.struct, expected a struct"},
+		{"{a: 1}", "2", "Cannot merge a patch of type int, expected a struct"},
+	}
+	for _, test := range tests {
+		_, err := mustParse(t, test.base).Merge(*mustParse(t, test.patch))
+		if got := errorString(err); got != test.err {
+			t.Errorf("%s.Merge(%s): got error %q, want %q", test.base, test.patch, got, test.err)
+		}
+	}
+}
+
+func TestTypeString(t *testing.T) {
+	tests := []struct {
+		typ  Type
+		want string
+	}{
+		{NullType, "null"},
+		{BoolType, "bool"},
+		{IntType, "int"},
+		{FloatType, "float"},
+		{DecimalType, "decimal"},
+		{TimestampType, "timestamp"},
+		{StringType, "string"},
+		{SymbolType, "symbol"},
+		{BlobType, "blob"},
+		{ClobType, "clob"},
+		{StructType, "struct"},
+		{ListType, "list"},
+		{SexpType, "sexp"},
+		{Type(99), "Type(99)"},
+	}
+	for _, test := range tests {
+		if got := test.typ.String(); got != test.want {
+			t.Errorf("Type(%d).String(): got %s, want %s", int(test.typ), got, test.want)
+		}
+	}
+}
+
+func TestTypeNames(t *testing.T) {
+	tests := []struct {
+		src, want string
+		null      bool
+	}{
+		{"1", "int", false},
+		{"null", "null", true},
+		{"null.null", "null", true},
+		{"null.int", "null.int", true},
+		{"null.struct", "null.struct", true},
+		{"{}", "struct", false},
+	}
+	for _, test := range tests {
+		v := mustParse(t, test.src)
+		if got := v.typeName(); got != test.want {
+			t.Errorf("%s.typeName(): got %s, want %s", test.src, got, test.want)
+		}
+		if got := v.IsNull(); got != test.null {
+			t.Errorf("%s.IsNull(): got %v, want %v", test.src, got, test.null)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	v := mustParse(t, `{a: {b: [10, {c: "x"}, (p q)]}, 'd': 4, d: 5}`)
+	tests := []struct {
+		path string
+		want string //empty if the path should not be found
+	}{
+		{"", `{a: {b: [10, {c: "x"}, (p q)]}, d: 4, d: 5}`},
+		{"a.b[0]", "10"},
+		{"a.b[1].c", `"x"`},
+		{"a.b[2][1]", "q"},
+		{"a.b[01]", `{c: "x"}`},
+		{"d", "4"},
+		{"a.b[3]", ""},
+		{"a.b[+1]", ""},
+		{"a.b[-1]", ""},
+		{"a.b[ 1]", ""},
+		{"a.b[]", ""},
+		{"a.b[1", ""},
+		{"a.b[99999999999999999999]", ""},
+		{"a[0]", ""},
+		{"a..b", ""},
+		{"a.x", ""},
+		{"a.b[0]c", ""},
+	}
+	for _, test := range tests {
+		got, ok := v.Path(test.path)
+		if ok != (test.want != "") || ok && got.String() != test.want {
+			t.Errorf("Path(%q): got %v, %v, want %q", test.path, got, ok, test.want)
+		}
+	}
+	list := mustParse(t, "[[1, 2]]")
+	if got, ok := list.Path("[0][1]"); !ok || got.String() != "2" {
+		t.Errorf("Path([0][1]): got %v, %v, want 2", got, ok)
+	}
+}